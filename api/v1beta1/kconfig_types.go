@@ -31,6 +31,22 @@ type KconfigSpec struct {
 	Selector          metav1.LabelSelector  `json:"selector"`
 	EnvConfigs        []EnvConfig           `json:"envConfigs"`
 	ContainerSelector *metav1.LabelSelector `json:"containerSelector"`
+	// NonMutating keeps the controller from rewriting envConfigs, generated references are kept in status instead.
+	// Defaults to the manager setting when unset.
+	// +kubebuilder:validation:Optional
+	NonMutating *bool `json:"nonMutating,omitempty"`
+	// ImmutableRevisions writes generated config into immutable ConfigMaps and Secrets named by a hash of their content
+	// +kubebuilder:validation:Optional
+	ImmutableRevisions bool `json:"immutableRevisions,omitempty"`
+	// RevisionHistoryLimit is the number of immutable revisions retained, defaults to 5
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// HistoryLimit is the number of KconfigRevisions retained, defaults to 10. Zero records no history.
+	// Revisions that pods or pod templates are pinned to are retained beyond the limit.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
 }
 
 // EnvConfig represents a single environment variable configuration
@@ -41,6 +57,10 @@ type EnvConfig struct {
 	Key  string `json:"key"`
 	// +kubebuilder:validation:Optional
 	Value *string `json:"value,omitempty"`
+	// EncryptedValue is a value encrypted against the controller's public key, only valid for Secret type.
+	// It is decrypted by the controller and written in plaintext only into the generated Secret.
+	// +kubebuilder:validation:Optional
+	EncryptedValue *string `json:"encryptedValue,omitempty"`
 	// +kubebuilder:validation:Optional
 	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
 	// +kubebuilder:validation:Optional
@@ -53,12 +73,71 @@ type EnvConfig struct {
 
 // KconfigStatus defines the observed state of Kconfig.
 type KconfigStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the generation of the Kconfig last processed by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions holds the Ready and Degraded conditions of the Kconfig
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ConfigMapName is the name of the ConfigMap generated for ConfigMap type EnvConfigs
+	// +kubebuilder:validation:Optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// SecretName is the name of the Secret generated for Secret type EnvConfigs
+	// +kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+	// KconfigBindingName is the name of the KconfigBinding generated from this Kconfig
+	// +kubebuilder:validation:Optional
+	KconfigBindingName string `json:"kconfigBindingName,omitempty"`
+	// CurrentRevision is the name of the KconfigRevision of the materialized configuration
+	// +kubebuilder:validation:Optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+	// EnvConfigs reports the result of processing each EnvConfig of the spec
+	// +kubebuilder:validation:Optional
+	EnvConfigs []EnvConfigStatus `json:"envConfigs,omitempty"`
+	// OrphanedKeys lists keys of the generated ConfigMap and Secret that are no longer referenced,
+	// they are pruned once unreferenced for longer than the grace period
+	// +kubebuilder:validation:Optional
+	OrphanedKeys []OrphanedKey `json:"orphanedKeys,omitempty"`
+}
+
+// OrphanedKey represents an unreferenced key of a generated ConfigMap or Secret
+type OrphanedKey struct {
+	// Kind is either ConfigMap or Secret
+	Kind string `json:"kind"`
+	// Key is the unreferenced key
+	Key string `json:"key"`
+	// Since is when the key was first found unreferenced
+	Since metav1.Time `json:"since"`
+}
+
+// EnvConfigStatus represents the result of processing a single EnvConfig
+type EnvConfigStatus struct {
+	// Key is the key of the EnvConfig this status is for
+	Key string `json:"key"`
+	// Type is the type of the EnvConfig, e.g. Value, ConfigMap or Secret
+	Type string `json:"type"`
+	// Result is one of Applied, Invalid or Failed
+	Result string `json:"result"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+	// ConfigMapKeyRef is the generated reference for a ConfigMap type EnvConfig with a value
+	// +kubebuilder:validation:Optional
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef is the generated reference for a Secret type EnvConfig with a value
+	// +kubebuilder:validation:Optional
+	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ValueHash identifies the value stored under the generated reference so it can be reused
+	// +kubebuilder:validation:Optional
+	ValueHash string `json:"valueHash,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Kconfig is the Schema for the kconfigs API.
 type Kconfig struct {
@@ -29,13 +29,173 @@ type KconfigBindingSpec struct {
 	Level int         `json:"level"`
 	Envs  []v1.EnvVar `json:"envs"`
 	// +kubebuilder:validation:Optional
-	Selector          metav1.LabelSelector  `json:"selector"`
+	Selector metav1.LabelSelector `json:"selector"`
+	// ContainerSelector selects the containers of a pod to inject into by the labels name, type, index
+	// (among the containers of the type), image (last repository path segment), image-repository (with
+	// / and : replaced by _) and image-tag, and the labels declared in the pod annotation
+	// kconfigcontroller.atteg.com/container-labels.<container name> as comma separated key=value pairs.
+	// The type is main, init or sidecar (an init container with restartPolicy Always). Selectors
+	// without a requirement on the type only select main containers.
 	ContainerSelector *metav1.LabelSelector `json:"containerSelector"`
+	// RolloutStrategy rolls the selected deployments, statefulSets, daemonSets and configured workload
+	// kinds gradually instead of all at once. CronJobs and Jobs are always refreshed immediately.
+	// A deleted binding is kept until its workloads are rolled off it and available again.
+	// +kubebuilder:validation:Optional
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+	// AutoRollback restores the envs of the last healthy rollout when workloads fail to roll out a new
+	// config, i.e. a Deployment exceeds its progress deadline or pods with the new config crash-loop
+	// +kubebuilder:validation:Optional
+	AutoRollback bool `json:"autoRollback,omitempty"`
+	// MaintenanceWindows restrict config rollouts to these windows. Outside of them only CronJobs are
+	// refreshed, new pods are injected with the config last rolled out and the rollout is pending until
+	// the next window opens, unless the binding is annotated with
+	// kconfigcontroller.atteg.com/rollout-window-override=true or is rolling back. Rolling the workloads
+	// off a deleted binding waits for a window as well.
+	// +kubebuilder:validation:Optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// EvictStalePods evicts the selected pods annotated with kconfigcontroller.atteg.com/inject that
+	// were injected with an older config and are not rolled through an opted-in pod template, honouring
+	// PodDisruptionBudgets. Evicted pods without an owner are not recreated.
+	// +kubebuilder:validation:Optional
+	EvictStalePods bool `json:"evictStalePods,omitempty"`
+	// Canary injects candidate envs into a percentage of the selected pods and the envs into the rest.
+	// A canary is promoted by making its envs the envs and removing it, and aborted by removing it.
+	// +kubebuilder:validation:Optional
+	Canary *Canary `json:"canary,omitempty"`
+}
+
+// Canary is a candidate env set injected into a deterministic share of the pods
+type Canary struct {
+	// Envs are the candidate envs
+	Envs []v1.EnvVar `json:"envs"`
+	// Percent is the share of pods injected with the candidate envs
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percent int32 `json:"percent"`
+	// BucketBy selects whether pods are bucketed by their name (Pod) or by the name of their workload
+	// (Owner), in which case all pods of a Deployment, StatefulSet, DaemonSet or Job get the same envs
+	// across rollouts. In Pod mode, pods created with a generated name, like those of Deployments and
+	// Jobs, get the variant the pods of their workload with the same config are short of, so that the
+	// share holds as pods are recreated, and pods without a workload are placed at random.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Pod;Owner
+	// +kubebuilder:default=Pod
+	BucketBy string `json:"bucketBy,omitempty"`
+}
+
+// MaintenanceWindow is a recurring window in which rollouts are allowed
+type MaintenanceWindow struct {
+	// Schedule is a standard cron expression (minute hour day-of-month month day-of-week) or descriptor
+	// such as @daily of the window starts. A day matches if either a restricted day-of-month or a
+	// restricted day-of-week matches.
+	Schedule string `json:"schedule"`
+	// Duration is how long the window stays open after each start
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone is the IANA time zone of the schedule, UTC when empty
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// RolloutStrategy orders the workloads of a rollout into waves. A wave starts once every workload of
+// the previous wave is Available with the new config.
+// +kubebuilder:validation:XValidation:rule="!(has(self.waves) && has(self.waveLabel))",message="waves and waveLabel are mutually exclusive"
+type RolloutStrategy struct {
+	// MaxConcurrent is the number of workloads of a wave rolling at the same time, unlimited when 0
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrent int32 `json:"maxConcurrent,omitempty"`
+	// WaveLabel groups workloads into waves by the value of this workload label, in ascending order of
+	// the values (numeric when all values are integers). Unlabelled workloads form the last wave.
+	// +kubebuilder:validation:Optional
+	WaveLabel string `json:"waveLabel,omitempty"`
+	// Waves lists the workloads of each wave in order. Unlisted workloads form the last wave.
+	// +kubebuilder:validation:Optional
+	Waves []RolloutWave `json:"waves,omitempty"`
+}
+
+// RolloutWave lists workloads by name, optionally qualified by kind as kind/name
+type RolloutWave struct {
+	Workloads []string `json:"workloads"`
 }
 
 // KconfigBindingStatus defines the observed state of KconfigBinding.
 type KconfigBindingStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration"`
+	// ConfigHash is the hash of the envs and the referenced ConfigMap and Secret values last rolled out
+	// +kubebuilder:validation:Optional
+	ConfigHash string `json:"configHash,omitempty"`
+	// RolledOut is the config rolled out with ConfigHash. While a binding with maintenance windows is
+	// outside of them, its envs and canary are injected instead of the spec envs and canary.
+	// +kubebuilder:validation:Optional
+	RolledOut *RolledOutConfig `json:"rolledOut,omitempty"`
+	// Rollout is the progress of the rollout of a binding with a rollout strategy
+	// +kubebuilder:validation:Optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	// StableConfigHash is the config hash of the last rollout whose workloads all became available
+	// +kubebuilder:validation:Optional
+	StableConfigHash string `json:"stableConfigHash,omitempty"`
+	// StableEnvs are the envs rolled out with StableConfigHash
+	// +kubebuilder:validation:Optional
+	StableEnvs []v1.EnvVar `json:"stableEnvs,omitempty"`
+	// StableCanary is the canary rolled out with StableConfigHash, if any
+	// +kubebuilder:validation:Optional
+	StableCanary *Canary `json:"stableCanary,omitempty"`
+	// FailedConfigHash is the config hash of the last rollout detected as failed
+	// +kubebuilder:validation:Optional
+	FailedConfigHash string `json:"failedConfigHash,omitempty"`
+	// Rollback is set while the binding is rolled back from a failed config. Its envs and canary are
+	// injected instead of the spec envs and canary until these change.
+	// +kubebuilder:validation:Optional
+	Rollback *RollbackStatus `json:"rollback,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RolledOutConfig records the envs and canary of a rollout
+type RolledOutConfig struct {
+	// Envs are the envs rolled out
+	Envs []v1.EnvVar `json:"envs"`
+	// Canary is the canary rolled out, if any
+	// +kubebuilder:validation:Optional
+	Canary *Canary `json:"canary,omitempty"`
+}
+
+// RollbackStatus records an automatic rollback
+type RollbackStatus struct {
+	// FailedConfigHash is the config hash whose rollout failed
+	FailedConfigHash string `json:"failedConfigHash"`
+	// ConfigHash is the config hash rolled back to
+	ConfigHash string `json:"configHash"`
+	// Envs are the envs rolled back to
+	Envs []v1.EnvVar `json:"envs"`
+	// Canary is the canary rolled back to, if the stable rollout had one
+	// +kubebuilder:validation:Optional
+	Canary *Canary `json:"canary,omitempty"`
+	// Message describes the failure
+	Message string `json:"message"`
+	// Time is when the rollback happened
+	Time metav1.Time `json:"time"`
+}
+
+// RolloutStatus reports the progress of a rollout
+type RolloutStatus struct {
+	// ConfigHash is the config hash being rolled out
+	ConfigHash string `json:"configHash"`
+	// Phase is Progressing or Complete
+	Phase string `json:"phase"`
+	// Wave is the wave in progress, counting from 1
+	Wave int32 `json:"wave"`
+	// Waves is the number of waves of the rollout
+	Waves int32 `json:"waves"`
+	// UpdatedWorkloads is the number of workloads carrying the config hash
+	UpdatedWorkloads int32 `json:"updatedWorkloads"`
+	// TotalWorkloads is the number of workloads taking part in the rollout
+	TotalWorkloads int32 `json:"totalWorkloads"`
+	// Progressing lists the workloads of the current wave that are not yet Available, as kind/name
+	// +kubebuilder:validation:Optional
+	Progressing []string `json:"progressing,omitempty"`
 }
 
 // +kubebuilder:object:root=true
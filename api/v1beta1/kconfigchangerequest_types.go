@@ -0,0 +1,95 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KconfigChangeRequestSpec proposes new EnvConfigs for a Kconfig, or its deletion. The controller
+// applies them once the change request is approved by another user than the one who requested it.
+// +kubebuilder:validation:XValidation:rule="!(has(self.delete) && self.delete && has(self.envConfigs) && size(self.envConfigs) > 0)",message="envConfigs and delete are mutually exclusive"
+type KconfigChangeRequestSpec struct {
+	// KconfigName is the name of the Kconfig in the namespace of the change request. It is immutable,
+	// like the EnvConfigs and Delete.
+	KconfigName string `json:"kconfigName"`
+	// EnvConfigs replace the EnvConfigs of the Kconfig. Secret values must be given as encryptedValue
+	// or secretKeyRef.
+	// +kubebuilder:validation:Optional
+	EnvConfigs []EnvConfig `json:"envConfigs"`
+	// Delete deletes the Kconfig instead of replacing its EnvConfigs
+	// +kubebuilder:validation:Optional
+	Delete bool `json:"delete,omitempty"`
+	// Approved approves the change request
+	// +kubebuilder:validation:Optional
+	Approved bool `json:"approved,omitempty"`
+	// RequestedBy is the user who created the change request, recorded at admission
+	// +kubebuilder:validation:Optional
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// ApprovedBy is the user who approved the change request, recorded at admission
+	// +kubebuilder:validation:Optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+	// BaseGeneration is the generation of the Kconfig the change request is based on, recorded at
+	// admission unless given. Change requests are only admitted for a Kconfig the controller has
+	// processed, so that its rewrites of the spec are part of the recorded generation. The change
+	// request fails if the Kconfig has changed since.
+	// +kubebuilder:validation:Optional
+	BaseGeneration int64 `json:"baseGeneration,omitempty"`
+}
+
+// KconfigChangeRequestStatus defines the observed state of KconfigChangeRequest.
+type KconfigChangeRequestStatus struct {
+	// Phase is Pending until approved, then Applied or Failed
+	// +kubebuilder:validation:Optional
+	Phase string `json:"phase,omitempty"`
+	// Message explains a Failed phase
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+	// AppliedTime is when the EnvConfigs were applied to the Kconfig
+	// +kubebuilder:validation:Optional
+	AppliedTime *metav1.Time `json:"appliedTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Kconfig",type="string",JSONPath=".spec.kconfigName"
+// +kubebuilder:printcolumn:name="Requested By",type="string",JSONPath=".spec.requestedBy"
+// +kubebuilder:printcolumn:name="Approved By",type="string",JSONPath=".spec.approvedBy"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// KconfigChangeRequest is the Schema for the kconfigchangerequests API.
+type KconfigChangeRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KconfigChangeRequestSpec   `json:"spec,omitempty"`
+	Status KconfigChangeRequestStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KconfigChangeRequestList contains a list of KconfigChangeRequest.
+type KconfigChangeRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KconfigChangeRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KconfigChangeRequest{}, &KconfigChangeRequestList{})
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KconfigRevisionSpec is a snapshot of the resolved EnvConfigs of a Kconfig, written by the controller
+// on every effective change.
+type KconfigRevisionSpec struct {
+	// KconfigName is the name of the Kconfig this is a revision of
+	KconfigName string `json:"kconfigName"`
+	// Revision numbers the revisions of a Kconfig in order
+	Revision int64 `json:"revision"`
+	// Hash identifies the resolved EnvConfigs
+	Hash string `json:"hash"`
+	// Author is the field manager of the last change to the Kconfig spec
+	// +kubebuilder:validation:Optional
+	Author string `json:"author,omitempty"`
+	// Timestamp is when the revision was recorded
+	Timestamp metav1.Time `json:"timestamp"`
+	// EnvConfigs are the EnvConfigs of the Kconfig with the values of generated ConfigMap keys inlined.
+	// Values of generated Secret keys are kept in the Secret of the same name as the revision.
+	EnvConfigs []EnvConfig `json:"envConfigs"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Kconfig",type="string",JSONPath=".spec.kconfigName"
+// +kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".spec.revision"
+// +kubebuilder:printcolumn:name="Author",type="string",JSONPath=".spec.author"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// KconfigRevision is the Schema for the kconfigrevisions API.
+type KconfigRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KconfigRevisionSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KconfigRevisionList contains a list of KconfigRevision.
+type KconfigRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KconfigRevision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KconfigRevision{}, &KconfigRevisionList{})
+}
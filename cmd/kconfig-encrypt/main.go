@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kconfig-encrypt encrypts a value against the controller's public key for use as the encryptedValue
+// of a Secret type EnvConfig. The value is read from stdin unless -value is given.
+//
+//	openssl rsa -in key.pem -pubout -out pub.pem
+//	echo -n s3cr3t | kconfig-encrypt -public-key pub.pem
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/envelope"
+)
+
+func main() {
+	var publicKeyPath string
+	var value string
+	flag.StringVar(&publicKeyPath, "public-key", "", "Path to the PEM encoded RSA public key of the controller")
+	flag.StringVar(&value, "value", "", "Value to encrypt, read from stdin if empty")
+	flag.Parse()
+
+	if publicKeyPath == "" {
+		fmt.Fprintln(os.Stderr, "-public-key is required")
+		os.Exit(2)
+	}
+	keyPEM, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading public key: %s\n", err.Error())
+		os.Exit(1)
+	}
+	pub, err := envelope.ParsePublicKeyPEM(keyPEM)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error parsing public key: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	plaintext := []byte(value)
+	if value == "" {
+		plaintext, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading value: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	sealed, err := envelope.Encrypt(pub, plaintext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error encrypting value: %s\n", err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(sealed)
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"crypto/rsa"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
@@ -25,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"time"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -42,6 +45,7 @@ import (
 
 	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
 	"github.com/att-cloudnative-labs/kconfig-controller/internal/controller"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/envelope"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -67,6 +71,13 @@ func main() {
 	var configMapPrefix string
 	var secretPrefix string
 	var defaultContainerSelector string
+	var nonMutatingKconfigs bool
+	var decryptionKeyPath string
+	var orphanedKeyGracePeriod time.Duration
+	var jobRefreshPolicy string
+	var workloadKindsConfigPath string
+	var controllerUsername string
+	var envPrecedence string
 	var webhookPort int
 	var webhookCertPath, webhookCertName, webhookCertKey string
 
@@ -82,6 +93,26 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&configMapPrefix, "configmap-prefix", "kc-", "prefix added to name of configmaps created from kconfigs")
 	flag.StringVar(&secretPrefix, "secret-prefix", "kc-", "prefix added to the name of secrets created from kconfigs")
+	flag.BoolVar(&nonMutatingKconfigs, "non-mutating-kconfigs", false,
+		"If set, kconfig envConfigs are never rewritten and generated references are kept in status. "+
+			"Kconfigs can override this with spec.nonMutating")
+	flag.StringVar(&decryptionKeyPath, "decryption-key-path", "",
+		"Path to the PEM encoded RSA private key used to decrypt encryptedValue envConfigs, typically mounted from a Secret")
+	flag.DurationVar(&orphanedKeyGracePeriod, "orphaned-key-grace-period", 0,
+		"How long keys of generated configmaps and secrets are kept once no longer referenced before they are pruned. "+
+			"Pruning is disabled by default, as pods restarted after a key was pruned fail to start")
+	flag.StringVar(&jobRefreshPolicy, "job-refresh-policy", controller.IgnoreJobRefreshPolicy,
+		"Whether running jobs are left alone (Ignore) or recreated (Recreate) on config change. "+
+			"Jobs can override this with the kconfigcontroller.atteg.com/job-refresh-policy annotation")
+	flag.StringVar(&workloadKindsConfigPath, "workload-kinds-config", "",
+		"Path to a config file listing additional workload kinds and the path of their pod template. "+
+			"The manager role must be granted get, list, watch and update on those kinds")
+	flag.StringVar(&controllerUsername, "controller-username", "",
+		"The user the controller authenticates as, allowed to update protected kconfigs. "+
+			"Looked up with a SelfSubjectReview if not set")
+	flag.StringVar(&envPrecedence, "env-precedence", webhook2.BindingWinsEnvPrecedence,
+		"Whether an injected env replaces a container env of the same name (BindingWins), is dropped (ContainerWins) "+
+			"or rejects the pod (Reject). Pods can override this with the kconfigcontroller.atteg.com/env-precedence annotation")
 	flag.StringVar(&defaultContainerSelector, "default-container-selector", "{}", "default container selector if kconfig doesn't supply")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "/tmp/k8s-webhook-server/serving-certs", "The directory that contains the webhook certificate.")
 	flag.StringVar(&webhookCertName, "webhook-cert-name", "tls.crt", "The name of the webhook certificate file.")
@@ -140,6 +171,36 @@ func main() {
 		setupLog.Error(err, fmt.Sprintf("error parsing default-container-selector: %s", err.Error()))
 		os.Exit(1)
 	}
+	if !webhook2.ValidEnvPrecedence(envPrecedence) {
+		setupLog.Error(fmt.Errorf("invalid env-precedence %s", envPrecedence), "error parsing env-precedence")
+		os.Exit(1)
+	}
+	var decryptionKey *rsa.PrivateKey
+	if decryptionKeyPath != "" {
+		keyPEM, err := os.ReadFile(decryptionKeyPath)
+		if err != nil {
+			setupLog.Error(err, "error reading decryption-key-path")
+			os.Exit(1)
+		}
+		decryptionKey, err = envelope.ParsePrivateKeyPEM(keyPEM)
+		if err != nil {
+			setupLog.Error(err, "error parsing decryption key")
+			os.Exit(1)
+		}
+	}
+	var workloadKinds []controller.WorkloadKind
+	if workloadKindsConfigPath != "" {
+		workloadKindsConfig, err := os.ReadFile(workloadKindsConfigPath)
+		if err != nil {
+			setupLog.Error(err, "error reading workload-kinds-config")
+			os.Exit(1)
+		}
+		workloadKinds, err = controller.ParseWorkloadKindsConfig(workloadKindsConfig)
+		if err != nil {
+			setupLog.Error(err, "error parsing workload-kinds-config")
+			os.Exit(1)
+		}
+	}
 	setupLog.Info("setting up pod config injector webhook")
 
 	webhookServer := webhook.NewServer(webhook.Options{
@@ -194,29 +255,67 @@ func main() {
 	}
 
 	if err = (&controller.KconfigReconciler{
-		Client:          mgr.GetClient(),
-		Log:             ctrl.Log.WithName("controllers").WithName("Kconfig"),
-		Scheme:          mgr.GetScheme(),
-		Recorder:        mgr.GetEventRecorderFor("Kconfig"),
-		ConfigMapPrefix: configMapPrefix,
-		SecretPrefix:    secretPrefix,
+		Client:                 mgr.GetClient(),
+		Log:                    ctrl.Log.WithName("controllers").WithName("Kconfig"),
+		Scheme:                 mgr.GetScheme(),
+		Recorder:               mgr.GetEventRecorderFor("Kconfig"),
+		ConfigMapPrefix:        configMapPrefix,
+		SecretPrefix:           secretPrefix,
+		NonMutating:            nonMutatingKconfigs,
+		DecryptionKey:          decryptionKey,
+		OrphanedKeyGracePeriod: orphanedKeyGracePeriod,
+		PodReader:              mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Kconfig")
 		os.Exit(1)
 	}
 	if err = (&controller.KconfigBindingReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("KconfigBinding"),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Log:              ctrl.Log.WithName("controllers").WithName("KconfigBinding"),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorderFor("KconfigBinding"),
+		JobRefreshPolicy: jobRefreshPolicy,
+		WorkloadKinds:    workloadKinds,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KconfigBinding")
 		os.Exit(1)
 	}
 
-	if err = webhook2.SetupPodConfigInjectorWithManager(mgr, &containerSelector); err != nil {
+	if err = (&controller.KconfigChangeRequestReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("KconfigChangeRequest"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("KconfigChangeRequest"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KconfigChangeRequest")
+		os.Exit(1)
+	}
+
+	if controllerUsername == "" {
+		if controllerUsername, err = webhook2.ControllerUsername(context.Background(), mgr.GetClient()); err != nil {
+			setupLog.Error(err, "unable to look up controller username, set --controller-username")
+			os.Exit(1)
+		}
+	}
+	if err = webhook2.SetupPodConfigInjectorWithManager(mgr, &containerSelector, envPrecedence); err != nil {
 		setupLog.Error(err, "unable to setup pod config injector", "webhook", "Pod")
 		os.Exit(1)
 	}
+	if decryptionKey == nil {
+		setupLog.Info("no decryption key configured, kconfigs with plaintext secret values are rejected")
+	}
+	if err = webhook2.SetupKconfigSecretExtractorWithManager(mgr, decryptionKey); err != nil {
+		setupLog.Error(err, "unable to setup kconfig secret extractor", "webhook", "Kconfig")
+		os.Exit(1)
+	}
+	if err = webhook2.SetupKconfigProtectionWithManager(mgr, controllerUsername); err != nil {
+		setupLog.Error(err, "unable to setup kconfig protection", "webhook", "Kconfig")
+		os.Exit(1)
+	}
+	if err = webhook2.SetupKconfigChangeRequestWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to setup kconfig change request approval", "webhook", "KconfigChangeRequest")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
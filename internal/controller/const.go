@@ -1,8 +1,17 @@
 package controller
 
+import "time"
+
 const (
 	WarningEventType      = "Warning"
 	InvalidEnvConfigEvent = "InvalidEnvConfig"
+	RolloutFailedEvent    = "RolloutFailed"
+	RolledBackEvent       = "RolledBack"
+	RollbackFailedEvent   = "RollbackFailed"
+	NormalEventType       = "Normal"
+
+	ChangeRequestAppliedEvent = "ChangeRequestApplied"
+	ChangeRequestFailedEvent  = "ChangeRequestFailed"
 
 	ValueEnvConfigType            = "Value"
 	ConfigMapEnvConfigType        = "ConfigMap"
@@ -11,7 +20,89 @@ const (
 	ResourceFieldRefEnvConfigType = "ResourceFieldRef"
 
 	AllowTemplateUpdatesAnnotation = "kconfigcontroller.atteg.com/refresh-template"
-	GenerationAnnotationPrefix     = "kconfigcontroller.atteg.com/"
+	// GenerationAnnotationPrefix was used by earlier versions for per-binding generation annotations
+	GenerationAnnotationPrefix = "kconfigcontroller.atteg.com/"
+	// ConfigHashAnnotationPrefix is followed by the binding name in the pod template annotation holding its config hash
+	ConfigHashAnnotationPrefix = "config.kconfigcontroller.atteg.com/"
+	ConfigHashLength           = 16
+	// InjectedConfigAnnotationPrefix prefixes the pod annotations recording the config hash injected per binding
+	InjectedConfigAnnotationPrefix = "injected.kconfigcontroller.atteg.com/"
+
+	// ConfigMapRefsField and SecretRefsField index KconfigBindings by the objects their envs reference,
+	// including the canary, stable and rollback envs
+	ConfigMapRefsField = ".spec.envs.valueFrom.configMapKeyRef.name"
+	SecretRefsField    = ".spec.envs.valueFrom.secretKeyRef.name"
 
 	KconfigDisableTemplateRefresh = "kconfigcontroller.atteg.com/disable-template-refresh"
+
+	// JobRefreshPolicyAnnotation selects for a Job whether it is left alone (Ignore) or recreated (Recreate) on config change
+	JobRefreshPolicyAnnotation = "kconfigcontroller.atteg.com/job-refresh-policy"
+	IgnoreJobRefreshPolicy     = "Ignore"
+	RecreateJobRefreshPolicy   = "Recreate"
+	// RecreatedJobAnnotation records on a recreated Job the name of the Job it replaces
+	RecreatedJobAnnotation = "kconfigcontroller.atteg.com/recreated-job"
+
+	KconfigCleanupFinalizer        = "kconfigcontroller.atteg.com/cleanup"
+	KconfigBindingRolloutFinalizer = "kconfigcontroller.atteg.com/rollout"
+	CleanupRequeueInterval         = 5 * time.Second
+
+	KconfigNameLabel            = "kconfigcontroller.atteg.com/kconfig"
+	RevisionHashLength          = 10
+	DefaultRevisionHistoryLimit = 5
+
+	// RollbackToAnnotation rolls a Kconfig back to the KconfigRevision of the given name or revision number
+	RollbackToAnnotation = "kconfigcontroller.atteg.com/rollback-to"
+	DefaultHistoryLimit  = 10
+	// FieldManager is the field manager of the controller's own Kconfig updates, which are no authored changes
+	FieldManager = "kconfig-controller"
+
+	ReadyCondition    = "Ready"
+	DegradedCondition = "Degraded"
+
+	MaterializedReason         = "Materialized"
+	InvalidEnvConfigReason     = "InvalidEnvConfig"
+	ConfigMapFailedReason      = "ConfigMapFailed"
+	SecretFailedReason         = "SecretFailed"
+	KconfigBindingFailedReason = "KconfigBindingFailed"
+	KconfigUpdateFailedReason  = "KconfigUpdateFailed"
+	KeyPruneFailedReason       = "KeyPruneFailed"
+	RevisionFailedReason       = "RevisionFailed"
+	HistoryFailedReason        = "HistoryFailed"
+
+	EnvConfigAppliedResult = "Applied"
+	EnvConfigInvalidResult = "Invalid"
+	EnvConfigFailedResult  = "Failed"
+
+	RolloutProgressingPhase = "Progressing"
+	RolloutCompletePhase    = "Complete"
+	RolloutRequeueInterval  = 10 * time.Second
+
+	// RolloutWindowOverrideAnnotation lets a binding roll out outside of its maintenance windows
+	RolloutWindowOverrideAnnotation = "kconfigcontroller.atteg.com/rollout-window-override"
+	RolloutPendingCondition         = "RolloutPending"
+	OutsideMaintenanceWindowReason  = "OutsideMaintenanceWindow"
+	InvalidMaintenanceWindowReason  = "InvalidMaintenanceWindow"
+	InMaintenanceWindowReason       = "InMaintenanceWindow"
+	WindowOverrideReason            = "WindowOverride"
+	RollingBackReason               = "RollingBack"
+	UpToDateReason                  = "UpToDate"
+
+	// InjectConfigAnnotation opts a pod into config injection
+	InjectConfigAnnotation = "kconfigcontroller.atteg.com/inject"
+	// CanaryVariantAnnotationPrefix prefixes the pod annotations recording the canary variant injected per binding
+	CanaryVariantAnnotationPrefix = "variant.kconfigcontroller.atteg.com/"
+	CanaryVariant                 = "canary"
+	StableVariant                 = "stable"
+	OwnerCanaryBucketBy           = "Owner"
+	// ProtectedKconfigLabel protects a Kconfig from direct spec edits, which are proposed as KconfigChangeRequests instead
+	ProtectedKconfigLabel = "kconfigcontroller.atteg.com/protected"
+	// ChangeRequestFieldManagerPrefix is followed by the change request name in the field manager of its Kconfig update
+	ChangeRequestFieldManagerPrefix = "kconfigchangerequest/"
+	ChangeRequestPendingPhase       = "Pending"
+	ChangeRequestAppliedPhase       = "Applied"
+	ChangeRequestFailedPhase        = "Failed"
+
+	// PinRevisionAnnotation pins a pod template to the KconfigRevisions of the given comma separated names or hashes.
+	// A binding is not injected into a pod pinned to a revision name of its Kconfig that does not exist.
+	PinRevisionAnnotation = "kconfigcontroller.atteg.com/pin-revision"
 )
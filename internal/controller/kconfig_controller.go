@@ -18,21 +18,27 @@ package controller
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"strings"
 	"time"
 
 	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/envelope"
 )
 
 // KconfigReconciler reconciles a Kconfig object
@@ -43,11 +49,21 @@ type KconfigReconciler struct {
 	Recorder        record.EventRecorder
 	ConfigMapPrefix string
 	SecretPrefix    string
+	// NonMutating is the default for Kconfigs that do not set spec.nonMutating
+	NonMutating bool
+	// DecryptionKey opens the encryptedValue of Secret type EnvConfigs
+	DecryptionKey *rsa.PrivateKey
+	// OrphanedKeyGracePeriod is how long unreferenced generated keys are kept, zero disables pruning
+	OrphanedKeyGracePeriod time.Duration
+	// PodReader lists the pods of a namespace, e.g. straight from the API server so that the pods of
+	// the cluster are not cached for key pruning. The client is used when unset.
+	PodReader client.Reader
 }
 
 // +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigs/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -67,13 +83,51 @@ func (r *KconfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	return ctrl.Result{}, r.processKconfig(ctx, &kc)
+	if !kc.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&kc, KconfigCleanupFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		done, err := r.cleanupKconfig(ctx, &kc)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("error cleaning up kconfig: %s", err.Error())
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: CleanupRequeueInterval}, nil
+		}
+		controllerutil.RemoveFinalizer(&kc, KconfigCleanupFinalizer)
+		if err := r.Update(ctx, &kc); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error removing kconfig finalizer: %s", err.Error())
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(&kc, KconfigCleanupFinalizer) {
+		if err := r.Update(ctx, &kc); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error adding kconfig finalizer: %s", err.Error())
+		}
+	}
+	// the update of a rollback triggers the reconcile that processes it
+	if rolledBack, err := r.applyRollback(ctx, &kc); err != nil || rolledBack {
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	processErr := r.processKconfig(ctx, &kc)
+	if processErr == nil {
+		if result, processErr = r.pruneOrphanedKeys(ctx, &kc); processErr != nil {
+			processErr = r.kconfigFailure(&kc, KeyPruneFailedReason, fmt.Errorf("error pruning orphaned keys: %s", processErr.Error()))
+		}
+	}
+	if err := r.updateKconfigStatus(ctx, &kc); err != nil && processErr == nil {
+		return ctrl.Result{}, err
+	}
+	return result, processErr
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KconfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kconfigcontrollerv1beta1.Kconfig{}).
+		Owns(&kconfigcontrollerv1beta1.KconfigBinding{}).
 		Named("kconfig").
 		Complete(r)
 }
@@ -91,49 +145,214 @@ func (r *KconfigReconciler) processKconfig(ctx context.Context, kc *kconfigcontr
 	envVars := make([]v1.EnvVar, 0)
 	cmActions := make([]ExternalAction, 0)
 	secActions := make([]ExternalAction, 0)
-	envConfigs := kc.Spec.EnvConfigs
+	envConfigStatuses := make([]kconfigcontrollerv1beta1.EnvConfigStatus, 0)
+	failed := 0
+	envConfigs, err := r.resolveHistoryValues(ctx, kc, kc.Spec.EnvConfigs)
+	if err != nil {
+		return r.kconfigFailure(kc, HistoryFailedReason, fmt.Errorf("error resolving kconfigRevision values: %s", err.Error()))
+	}
+	if kc.Spec.ImmutableRevisions {
+		resolved, err := r.resolveRevisionValues(ctx, kc, envConfigs)
+		if err != nil {
+			return r.kconfigFailure(kc, RevisionFailedReason, fmt.Errorf("error resolving revision values: %s", err.Error()))
+		}
+		envConfigs = resolved
+	}
 	for _, ec := range envConfigs {
-		switch strings.ToLower(ec.Type) {
-		case "value", "": // value is default type
-			if err := r.processValueEnvConfig(ec, &envVars, &updatedEnvConfigs); err != nil {
-				return fmt.Errorf("error processing value envConfig: %s", err.Error())
-			}
-		case "configmap":
-			if err := r.processConfigMapEnvConfig(kc, ec, &cmActions, &envVars, &updatedEnvConfigs); err != nil {
-				return fmt.Errorf("error processing configmap envConfig: %s", err.Error())
-			}
-		case "secret":
-			if err := r.processSecretEnvConfig(kc, ec, &secActions, &envVars, &updatedEnvConfigs); err != nil {
-				return fmt.Errorf("error processing secret envConfig: %s", err.Error())
-			}
-		case "fieldref":
-			if err := r.processFieldRefEnvConfig(ec, &envVars, &updatedEnvConfigs); err != nil {
-				return fmt.Errorf("error processing fieldRef envConfig: %s", err.Error())
-			}
-		case "resourcefieldref":
-			if err := r.processResourceFieldRefEnvConfig(ec, &envVars, &updatedEnvConfigs); err != nil {
-				return fmt.Errorf("error processing resourceFieldRef envConfig: %s", err.Error())
-			}
-		default:
-			return fmt.Errorf("invalid EnvConfig type, %s", ec.Type)
+		processed := len(updatedEnvConfigs)
+		ecStatus := kconfigcontrollerv1beta1.EnvConfigStatus{Key: ec.Key, Type: ec.Type, Result: EnvConfigAppliedResult}
+		if err := r.processEnvConfig(kc, ec, &cmActions, &secActions, &envVars, &updatedEnvConfigs); err != nil {
+			ecStatus.Result = EnvConfigFailedResult
+			ecStatus.Message = err.Error()
+			failed++
+		} else if len(updatedEnvConfigs) == processed {
+			ecStatus.Result = EnvConfigInvalidResult
+			ecStatus.Message = "EnvConfig is incomplete and was removed"
+		} else {
+			updated := updatedEnvConfigs[len(updatedEnvConfigs)-1]
+			ecStatus.ConfigMapKeyRef = updated.ConfigMapKeyRef
+			ecStatus.SecretKeyRef = updated.SecretKeyRef
+			ecStatus.ValueHash = envConfigValueHash(kc, ec)
 		}
+		envConfigStatuses = append(envConfigStatuses, ecStatus)
 	}
-
-	if err := r.executeConfigMapActions(ctx, kc, cmActions); err != nil {
-		return fmt.Errorf("error executing configmap actions: %s", err.Error())
+	kc.Status.EnvConfigs = envConfigStatuses
+	if failed > 0 {
+		return r.kconfigFailure(kc, InvalidEnvConfigReason, fmt.Errorf("%d of %d envConfigs could not be processed", failed, len(envConfigs)))
 	}
-	if err := r.executeSecretActions(ctx, kc, secActions); err != nil {
-		return fmt.Errorf("error executing secret actions: %s", err.Error())
+
+	cmName := fmt.Sprintf("%s%s", r.ConfigMapPrefix, kc.Name)
+	secName := fmt.Sprintf("%s%s", r.SecretPrefix, kc.Name)
+	if kc.Spec.ImmutableRevisions {
+		cmRevision := revisionName(kc, cmName, cmActions)
+		secRevision := revisionName(kc, secName, secActions)
+		renameRevisionRefs(envVars, updatedEnvConfigs, envConfigStatuses, cmName, cmRevision, secName, secRevision)
+		cmName, secName = cmRevision, secRevision
+		if err := r.executeConfigMapRevision(ctx, kc, cmName, cmActions); err != nil {
+			return r.kconfigFailure(kc, ConfigMapFailedReason, fmt.Errorf("error creating configmap revision: %s", err.Error()))
+		}
+		if err := r.executeSecretRevision(ctx, kc, secName, secActions); err != nil {
+			return r.kconfigFailure(kc, SecretFailedReason, fmt.Errorf("error creating secret revision: %s", err.Error()))
+		}
+	} else {
+		if err := r.executeConfigMapActions(ctx, kc, cmActions); err != nil {
+			return r.kconfigFailure(kc, ConfigMapFailedReason, fmt.Errorf("error executing configmap actions: %s", err.Error()))
+		}
+		if err := r.executeSecretActions(ctx, kc, secActions); err != nil {
+			return r.kconfigFailure(kc, SecretFailedReason, fmt.Errorf("error executing secret actions: %s", err.Error()))
+		}
 	}
 	if err := r.updateKconfigBinding(ctx, kc, envVars); err != nil {
-		return fmt.Errorf("error on update of kconfigbinding: %s", err.Error())
+		return r.kconfigFailure(kc, KconfigBindingFailedReason, fmt.Errorf("error on update of kconfigbinding: %s", err.Error()))
+	}
+	if kc.Spec.ImmutableRevisions {
+		if err := r.pruneRevisions(ctx, kc, cmName, secName); err != nil {
+			return r.kconfigFailure(kc, RevisionFailedReason, fmt.Errorf("error pruning revisions: %s", err.Error()))
+		}
+	}
+	if err := r.recordRevision(ctx, kc, updatedEnvConfigs, cmName, secName, cmActions, secActions); err != nil {
+		return r.kconfigFailure(kc, HistoryFailedReason, fmt.Errorf("error recording kconfigRevision: %s", err.Error()))
+	}
+	// update kconfig, unless the spec is owned by an external source of truth
+	if !r.isNonMutating(kc) {
+		status := kc.Status
+		kc.Spec.EnvConfigs = updatedEnvConfigs
+		if err := r.Update(ctx, kc, client.FieldOwner(FieldManager)); err != nil {
+			kc.Status = status
+			return r.kconfigFailure(kc, KconfigUpdateFailedReason, fmt.Errorf("error updating kconfig: %s", err.Error()))
+		}
+		// the returned object carries the previously persisted status
+		kc.Status = status
 	}
-	// update kconfig
-	kcCopy := kc.DeepCopy()
-	kcCopy.Spec.EnvConfigs = updatedEnvConfigs
+	setMaterializedRefs(kc, envVars, cmName, secName)
+	meta.SetStatusCondition(&kc.Status.Conditions, metav1.Condition{
+		Type:               ReadyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             MaterializedReason,
+		Message:            "configuration materialized into kconfigbinding",
+		ObservedGeneration: kc.Generation,
+	})
+	meta.SetStatusCondition(&kc.Status.Conditions, metav1.Condition{
+		Type:               DegradedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             MaterializedReason,
+		ObservedGeneration: kc.Generation,
+	})
+	return nil
+}
+
+func (r *KconfigReconciler) processEnvConfig(kc *kconfigcontrollerv1beta1.Kconfig, ec kconfigcontrollerv1beta1.EnvConfig, cmActions, secActions *[]ExternalAction, envVars *[]v1.EnvVar, updatedECs *[]kconfigcontrollerv1beta1.EnvConfig) error {
+	if ec.EncryptedValue != nil && strings.ToLower(ec.Type) != "secret" {
+		return fmt.Errorf("encryptedValue is only supported for %s type envConfigs", SecretEnvConfigType)
+	}
+	switch strings.ToLower(ec.Type) {
+	case "value", "": // value is default type
+		if err := r.processValueEnvConfig(ec, envVars, updatedECs); err != nil {
+			return fmt.Errorf("error processing value envConfig: %s", err.Error())
+		}
+	case "configmap":
+		if err := r.processConfigMapEnvConfig(kc, ec, cmActions, envVars, updatedECs); err != nil {
+			return fmt.Errorf("error processing configmap envConfig: %s", err.Error())
+		}
+	case "secret":
+		if err := r.processSecretEnvConfig(kc, ec, secActions, envVars, updatedECs); err != nil {
+			return fmt.Errorf("error processing secret envConfig: %s", err.Error())
+		}
+	case "fieldref":
+		if err := r.processFieldRefEnvConfig(ec, envVars, updatedECs); err != nil {
+			return fmt.Errorf("error processing fieldRef envConfig: %s", err.Error())
+		}
+	case "resourcefieldref":
+		if err := r.processResourceFieldRefEnvConfig(ec, envVars, updatedECs); err != nil {
+			return fmt.Errorf("error processing resourceFieldRef envConfig: %s", err.Error())
+		}
+	default:
+		return fmt.Errorf("invalid EnvConfig type, %s", ec.Type)
+	}
+	return nil
+}
+
+func (r *KconfigReconciler) isNonMutating(kc *kconfigcontrollerv1beta1.Kconfig) bool {
+	if kc.Spec.NonMutating != nil {
+		return *kc.Spec.NonMutating
+	}
+	return r.NonMutating
+}
 
-	if err := r.Update(ctx, kcCopy); err != nil {
-		return fmt.Errorf("error updating kconfig: %s", err.Error())
+// previousEnvConfigStatus returns the status entry recorded for the same key and value on the last
+// reconcile, so that generated keys stay stable while the spec value is unchanged
+func previousEnvConfigStatus(kc *kconfigcontrollerv1beta1.Kconfig, ec kconfigcontrollerv1beta1.EnvConfig) *kconfigcontrollerv1beta1.EnvConfigStatus {
+	hash := envConfigValueHash(kc, ec)
+	if hash == "" {
+		return nil
+	}
+	for i, ecStatus := range kc.Status.EnvConfigs {
+		if ecStatus.Key == ec.Key && ecStatus.ValueHash == hash {
+			return &kc.Status.EnvConfigs[i]
+		}
+	}
+	return nil
+}
+
+// envConfigValueHash hashes the value or, as it is never decrypted outside the controller, the encrypted value
+func envConfigValueHash(kc *kconfigcontrollerv1beta1.Kconfig, ec kconfigcontrollerv1beta1.EnvConfig) string {
+	switch {
+	case ec.Value != nil:
+		return valueHash(kc, *ec.Value)
+	case ec.EncryptedValue != nil:
+		return valueHash(kc, *ec.EncryptedValue)
+	}
+	return ""
+}
+
+// valueHash hashes a value salted with the Kconfig UID so secret values can't be looked up from status
+func valueHash(kc *kconfigcontrollerv1beta1.Kconfig, value string) string {
+	sum := sha256.Sum256([]byte(string(kc.UID) + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// kconfigFailure marks the Kconfig as not ready and degraded for the given reason and returns err
+func (r *KconfigReconciler) kconfigFailure(kc *kconfigcontrollerv1beta1.Kconfig, reason string, err error) error {
+	meta.SetStatusCondition(&kc.Status.Conditions, metav1.Condition{
+		Type:               ReadyCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: kc.Generation,
+	})
+	meta.SetStatusCondition(&kc.Status.Conditions, metav1.Condition{
+		Type:               DegradedCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: kc.Generation,
+	})
+	return err
+}
+
+// setMaterializedRefs records the names of the generated objects referenced by the binding envs
+func setMaterializedRefs(kc *kconfigcontrollerv1beta1.Kconfig, envVars []v1.EnvVar, cmName, secName string) {
+	kc.Status.ConfigMapName = ""
+	kc.Status.SecretName = ""
+	for _, envVar := range envVars {
+		if envVar.ValueFrom == nil {
+			continue
+		}
+		if ref := envVar.ValueFrom.ConfigMapKeyRef; ref != nil && ref.Name == cmName {
+			kc.Status.ConfigMapName = cmName
+		}
+		if ref := envVar.ValueFrom.SecretKeyRef; ref != nil && ref.Name == secName {
+			kc.Status.SecretName = secName
+		}
+	}
+	kc.Status.KconfigBindingName = kc.Name
+}
+
+// updateKconfigStatus persists the status assembled during processing
+func (r *KconfigReconciler) updateKconfigStatus(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig) error {
+	kc.Status.ObservedGeneration = kc.Generation
+	if err := r.Status().Update(ctx, kc); err != nil {
+		return fmt.Errorf("error updating kconfig status: %s", err.Error())
 	}
 	return nil
 }
@@ -153,6 +372,12 @@ func (r *KconfigReconciler) processConfigMapEnvConfig(kc *kconfigcontrollerv1bet
 	if ec.Value != nil {
 		refName := fmt.Sprintf("%s%s", r.ConfigMapPrefix, kc.Name)
 		refKey := uuid.New().String()
+		if kc.Spec.ImmutableRevisions {
+			// revisions hold a single value per key
+			refKey = ec.Key
+		} else if prev := previousEnvConfigStatus(kc, ec); prev != nil && prev.ConfigMapKeyRef != nil && prev.ConfigMapKeyRef.Name == refName {
+			refKey = prev.ConfigMapKeyRef.Key
+		}
 		configMapKeyRef := &v1.ConfigMapKeySelector{
 			LocalObjectReference: v1.LocalObjectReference{
 				Name: refName,
@@ -187,10 +412,27 @@ func (r *KconfigReconciler) processConfigMapEnvConfig(kc *kconfigcontrollerv1bet
 
 func (r *KconfigReconciler) processSecretEnvConfig(kc *kconfigcontrollerv1beta1.Kconfig, ec kconfigcontrollerv1beta1.EnvConfig, actions *[]ExternalAction, envVars *[]v1.EnvVar, updatedECs *[]kconfigcontrollerv1beta1.EnvConfig) error {
 	envVar := v1.EnvVar{}
-	if ec.Value != nil {
+	value := ec.Value
+	if ec.EncryptedValue != nil {
+		if r.DecryptionKey == nil {
+			return fmt.Errorf("no decryption key configured for encryptedValue of %s", ec.Key)
+		}
+		plaintext, err := envelope.Decrypt(r.DecryptionKey, *ec.EncryptedValue)
+		if err != nil {
+			return fmt.Errorf("error decrypting value of %s: %s", ec.Key, err.Error())
+		}
+		decrypted := string(plaintext)
+		value = &decrypted
+	}
+	if value != nil {
 		refName := fmt.Sprintf("%s%s", r.SecretPrefix, kc.Name)
 		timestamp := time.Now().Format("20060102")
 		refKey := fmt.Sprintf("%s_%s", ec.Key, timestamp)
+		if kc.Spec.ImmutableRevisions {
+			refKey = ec.Key
+		} else if prev := previousEnvConfigStatus(kc, ec); prev != nil && prev.SecretKeyRef != nil && prev.SecretKeyRef.Name == refName {
+			refKey = prev.SecretKeyRef.Key
+		}
 		secretKeyRef := &v1.SecretKeySelector{
 			LocalObjectReference: v1.LocalObjectReference{
 				Name: refName,
@@ -202,7 +444,7 @@ func (r *KconfigReconciler) processSecretEnvConfig(kc *kconfigcontrollerv1beta1.
 		envVar.ValueFrom = &v1.EnvVarSource{
 			SecretKeyRef: secretKeyRef,
 		}
-		*actions = append(*actions, ExternalAction{Key: refKey, Value: *ec.Value})
+		*actions = append(*actions, ExternalAction{Key: refKey, Value: *value})
 		*envVars = append(*envVars, envVar)
 		*updatedECs = append(*updatedECs, kconfigcontrollerv1beta1.EnvConfig{
 			Type:         SecretEnvConfigType,
@@ -303,9 +545,15 @@ func (r *KconfigReconciler) executeConfigMapActions(ctx context.Context, kc *kco
 			return fmt.Errorf("error getting configmap: %s", err.Error())
 		}
 	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
 	for _, action := range actions {
 		cm.Data[action.Key] = action.Value
 	}
+	if err := controllerutil.SetControllerReference(kc, &cm, r.Scheme); err != nil {
+		return fmt.Errorf("error setting configmap owner: %s", err.Error())
+	}
 
 	if existing {
 		if err := r.Update(ctx, &cm); err != nil {
@@ -341,9 +589,15 @@ func (r *KconfigReconciler) executeSecretActions(ctx context.Context, kc *kconfi
 			return fmt.Errorf("error getting secret: %s", err.Error())
 		}
 	}
+	if sec.Data == nil {
+		sec.Data = make(map[string][]byte)
+	}
 	for _, action := range actions {
 		sec.Data[action.Key] = []byte(action.Value)
 	}
+	if err := controllerutil.SetControllerReference(kc, &sec, r.Scheme); err != nil {
+		return fmt.Errorf("error setting secret owner: %s", err.Error())
+	}
 
 	if existing {
 		if err := r.Update(ctx, &sec); err != nil {
@@ -370,14 +624,6 @@ func (r *KconfigReconciler) updateKconfigBinding(ctx context.Context, kc *kconfi
 					Name:        kc.Name,
 					Labels:      kc.Labels,
 					Annotations: kc.Annotations,
-					OwnerReferences: []metav1.OwnerReference{
-						{
-							Kind:       kc.Kind,
-							APIVersion: kc.APIVersion,
-							Name:       kc.Name,
-							UID:        kc.UID,
-						},
-					},
 				},
 				Spec: kconfigcontrollerv1beta1.KconfigBindingSpec{
 					Level:             0,
@@ -396,6 +642,17 @@ func (r *KconfigReconciler) updateKconfigBinding(ctx context.Context, kc *kconfi
 	kcb.Spec.Level = kc.Spec.Level
 	kcb.Spec.Envs = envVars
 	kcb.Spec.Selector = kc.Spec.Selector
+	// drop references written before controller references were used
+	ownerRefs := make([]metav1.OwnerReference, 0)
+	for _, ownerRef := range kcb.OwnerReferences {
+		if ownerRef.UID != kc.UID {
+			ownerRefs = append(ownerRefs, ownerRef)
+		}
+	}
+	kcb.OwnerReferences = ownerRefs
+	if err := controllerutil.SetControllerReference(kc, &kcb, r.Scheme); err != nil {
+		return fmt.Errorf("error setting kconfigBinding owner: %s", err.Error())
+	}
 
 	if existing {
 		if err := r.Update(ctx, &kcb); err != nil {
@@ -408,3 +665,112 @@ func (r *KconfigReconciler) updateKconfigBinding(ctx context.Context, kc *kconfi
 	}
 	return nil
 }
+
+// cleanupKconfig removes the KconfigBinding and then the keys this Kconfig owns in the generated
+// ConfigMap and Secret. Keys are only removed once the binding is gone, so that workloads are rolled
+// off the config before it disappears. It returns false while the binding is still being deleted.
+func (r *KconfigReconciler) cleanupKconfig(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig) (bool, error) {
+	var kcb kconfigcontrollerv1beta1.KconfigBinding
+	nn := types.NamespacedName{Namespace: kc.Namespace, Name: kc.Name}
+	if err := r.Get(ctx, nn, &kcb); err == nil {
+		if isOwnedBy(kcb.OwnerReferences, kc.UID) {
+			if kcb.DeletionTimestamp.IsZero() {
+				if err := r.Delete(ctx, &kcb); client.IgnoreNotFound(err) != nil {
+					return false, fmt.Errorf("error deleting kconfigBinding: %s", err.Error())
+				}
+			}
+			return false, nil
+		}
+	} else if !errors.IsNotFound(err) {
+		return false, fmt.Errorf("error getting kconfigBinding: %s", err.Error())
+	}
+
+	cmKeys, secKeys := r.ownedKeys(kc)
+	if err := r.removeConfigMapKeys(ctx, kc, cmKeys); err != nil {
+		return false, err
+	}
+	if err := r.removeSecretKeys(ctx, kc, secKeys); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func isOwnedBy(ownerRefs []metav1.OwnerReference, uid types.UID) bool {
+	for _, ownerRef := range ownerRefs {
+		if ownerRef.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedKeys returns the keys of the generated ConfigMap and Secret referenced by the Kconfig spec or status
+func (r *KconfigReconciler) ownedKeys(kc *kconfigcontrollerv1beta1.Kconfig) ([]string, []string) {
+	cmName := fmt.Sprintf("%s%s", r.ConfigMapPrefix, kc.Name)
+	secName := fmt.Sprintf("%s%s", r.SecretPrefix, kc.Name)
+	cmKeys := make([]string, 0)
+	secKeys := make([]string, 0)
+	addRefs := func(cmRef *v1.ConfigMapKeySelector, secRef *v1.SecretKeySelector) {
+		if cmRef != nil && cmRef.Name == cmName {
+			cmKeys = append(cmKeys, cmRef.Key)
+		}
+		if secRef != nil && secRef.Name == secName {
+			secKeys = append(secKeys, secRef.Key)
+		}
+	}
+	for _, ec := range kc.Spec.EnvConfigs {
+		addRefs(ec.ConfigMapKeyRef, ec.SecretKeyRef)
+	}
+	for _, ecStatus := range kc.Status.EnvConfigs {
+		addRefs(ecStatus.ConfigMapKeyRef, ecStatus.SecretKeyRef)
+	}
+	return cmKeys, secKeys
+}
+
+func (r *KconfigReconciler) removeConfigMapKeys(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, keys []string) error {
+	var cm v1.ConfigMap
+	nn := types.NamespacedName{Namespace: kc.Namespace, Name: fmt.Sprintf("%s%s", r.ConfigMapPrefix, kc.Name)}
+	if err := r.Get(ctx, nn, &cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting configmap: %s", err.Error())
+	}
+	for _, key := range keys {
+		delete(cm.Data, key)
+	}
+	if len(cm.Data) == 0 && len(cm.BinaryData) == 0 {
+		if err := r.Delete(ctx, &cm); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting configmap: %s", err.Error())
+		}
+		return nil
+	}
+	if err := r.Update(ctx, &cm); err != nil {
+		return fmt.Errorf("error updating configmap: %s", err.Error())
+	}
+	return nil
+}
+
+func (r *KconfigReconciler) removeSecretKeys(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, keys []string) error {
+	var sec v1.Secret
+	nn := types.NamespacedName{Namespace: kc.Namespace, Name: fmt.Sprintf("%s%s", r.SecretPrefix, kc.Name)}
+	if err := r.Get(ctx, nn, &sec); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting secret: %s", err.Error())
+	}
+	for _, key := range keys {
+		delete(sec.Data, key)
+	}
+	if len(sec.Data) == 0 {
+		if err := r.Delete(ctx, &sec); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting secret: %s", err.Error())
+		}
+		return nil
+	}
+	if err := r.Update(ctx, &sec); err != nil {
+		return fmt.Errorf("error updating secret: %s", err.Error())
+	}
+	return nil
+}
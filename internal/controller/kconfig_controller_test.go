@@ -18,11 +18,16 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -77,8 +82,395 @@ var _ = Describe("Kconfig Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Reporting the Ready condition in status")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, kconfig)).To(Succeed())
+			Expect(kconfig.Status.ObservedGeneration).To(Equal(kconfig.Generation))
+			Expect(meta.IsStatusConditionTrue(kconfig.Status.Conditions, ReadyCondition)).To(BeTrue())
+			Expect(kconfig.Status.KconfigBindingName).To(Equal(resourceName))
+
+			By("Owning the generated KconfigBinding")
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, kcb)).To(Succeed())
+			Expect(metav1.IsControlledBy(kcb, kconfig)).To(BeTrue())
+			Expect(kconfig.Finalizers).To(ContainElement(KconfigCleanupFinalizer))
+		})
+	})
+	Context("When reconciling a non-mutating resource", func() {
+		const resourceName = "test-non-mutating"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating a non-mutating Kconfig with a configmap value")
+			nonMutating := true
+			value := "bar"
+			resource := &kconfigcontrollerv1beta1.Kconfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: kconfigcontrollerv1beta1.KconfigSpec{
+					NonMutating: &nonMutating,
+					EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{
+						{Type: ConfigMapEnvConfigType, Key: "FOO", Value: &value},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &kconfigcontrollerv1beta1.Kconfig{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+
+		It("should keep the spec and a stable reference in status", func() {
+			controllerReconciler := &KconfigReconciler{
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				ConfigMapPrefix: "kc-",
+				SecretPrefix:    "kc-",
+			}
+
+			kconfig := &kconfigcontrollerv1beta1.Kconfig{}
+			refKeys := make([]string, 0)
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, typeNamespacedName, kconfig)).To(Succeed())
+				Expect(kconfig.Spec.EnvConfigs[0].Value).NotTo(BeNil())
+				Expect(kconfig.Spec.EnvConfigs[0].ConfigMapKeyRef).To(BeNil())
+				Expect(kconfig.Status.EnvConfigs).To(HaveLen(1))
+				Expect(kconfig.Status.EnvConfigs[0].ConfigMapKeyRef).NotTo(BeNil())
+				refKeys = append(refKeys, kconfig.Status.EnvConfigs[0].ConfigMapKeyRef.Key)
+			}
+			Expect(refKeys[1]).To(Equal(refKeys[0]))
+		})
+	})
+	Context("When reconciling a resource with immutable revisions", func() {
+		const resourceName = "test-revisions"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating a Kconfig with immutable revisions")
+			value := "bar"
+			resource := &kconfigcontrollerv1beta1.Kconfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: kconfigcontrollerv1beta1.KconfigSpec{
+					ImmutableRevisions: true,
+					EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{
+						{Type: ConfigMapEnvConfigType, Key: "FOO", Value: &value},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &kconfigcontrollerv1beta1.Kconfig{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+
+		It("should point the binding at an immutable revision", func() {
+			controllerReconciler := &KconfigReconciler{
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				ConfigMapPrefix: "kc-",
+				SecretPrefix:    "kc-",
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, kcb)).To(Succeed())
+			Expect(kcb.Spec.Envs).To(HaveLen(1))
+			ref := kcb.Spec.Envs[0].ValueFrom.ConfigMapKeyRef
+			Expect(ref.Name).To(HavePrefix("kc-" + resourceName + "-"))
+			Expect(ref.Key).To(Equal("FOO"))
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: ref.Name}, cm)).To(Succeed())
+			Expect(cm.Immutable).NotTo(BeNil())
+			Expect(*cm.Immutable).To(BeTrue())
+			Expect(cm.Data).To(HaveKeyWithValue("FOO", "bar"))
+		})
+	})
+	Context("When naming immutable revisions", func() {
+		const resourceName = "test-revision-names"
+
+		ctx := context.Background()
+
+		It("should salt revision names and resolve values of the mutable generated objects", func() {
+			actions := []ExternalAction{{Key: "PASSWORD", Value: "hunter2"}}
+			kc := &kconfigcontrollerv1beta1.Kconfig{ObjectMeta: metav1.ObjectMeta{Name: resourceName, UID: "uid-1"}}
+			other := &kconfigcontrollerv1beta1.Kconfig{ObjectMeta: metav1.ObjectMeta{Name: resourceName, UID: "uid-2"}}
+			Expect(revisionName(kc, "kc-"+resourceName, actions)).To(HaveLen(len("kc-"+resourceName+"-") + RevisionHashLength))
+			Expect(revisionName(kc, "kc-"+resourceName, actions)).NotTo(Equal(revisionName(other, "kc-"+resourceName, actions)))
+
+			sec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "kc-" + resourceName, Namespace: "default"},
+				Data:       map[string][]byte{"PASSWORD_20260101": []byte("hunter2")},
+			}
+			Expect(k8sClient.Create(ctx, sec)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, sec)).To(Succeed())
+			}()
+			controllerReconciler := &KconfigReconciler{
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				ConfigMapPrefix: "kc-",
+				SecretPrefix:    "kc-",
+			}
+			kc.Namespace = "default"
+			resolved, err := controllerReconciler.resolveRevisionValues(ctx, kc, []kconfigcontrollerv1beta1.EnvConfig{{
+				Type: SecretEnvConfigType,
+				Key:  "PASSWORD",
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: sec.Name},
+					Key:                  "PASSWORD_20260101",
+				},
+			}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolved).To(HaveLen(1))
+			Expect(resolved[0].SecretKeyRef).To(BeNil())
+			Expect(*resolved[0].Value).To(Equal("hunter2"))
+		})
+	})
+	Context("When recording revision history", func() {
+		const resourceName = "test-history"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating a Kconfig with a configmap value")
+			value := "bar"
+			resource := &kconfigcontrollerv1beta1.Kconfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: kconfigcontrollerv1beta1.KconfigSpec{
+					EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{
+						{Type: ConfigMapEnvConfigType, Key: "FOO", Value: &value},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &kconfigcontrollerv1beta1.Kconfig{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+
+		It("should record revisions and roll back to one", func() {
+			controllerReconciler := &KconfigReconciler{
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				Recorder:        record.NewFakeRecorder(10),
+				ConfigMapPrefix: "kc-",
+				SecretPrefix:    "kc-",
+			}
+			reconcileKconfig := func() *kconfigcontrollerv1beta1.Kconfig {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				kc := &kconfigcontrollerv1beta1.Kconfig{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, kc)).To(Succeed())
+				return kc
+			}
+			revision := func(name string) *kconfigcontrollerv1beta1.KconfigRevision {
+				rev := &kconfigcontrollerv1beta1.KconfigRevision{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, rev)).To(Succeed())
+				return rev
+			}
+
+			By("recording the first revision")
+			kc := reconcileKconfig()
+			Expect(kc.Status.CurrentRevision).To(Equal(resourceName + "-rev-1"))
+			first := revision(resourceName + "-rev-1")
+			Expect(first.Spec.EnvConfigs).To(HaveLen(1))
+			Expect(*first.Spec.EnvConfigs[0].Value).To(Equal("bar"))
+
+			By("not recording a revision without an effective change")
+			kc = reconcileKconfig()
+			Expect(kc.Status.CurrentRevision).To(Equal(resourceName + "-rev-1"))
+
+			By("recording a revision of a changed value")
+			value := "baz"
+			kc.Spec.EnvConfigs = []kconfigcontrollerv1beta1.EnvConfig{{Type: ConfigMapEnvConfigType, Key: "FOO", Value: &value}}
+			Expect(k8sClient.Update(ctx, kc)).To(Succeed())
+			kc = reconcileKconfig()
+			Expect(kc.Status.CurrentRevision).To(Equal(resourceName + "-rev-2"))
+
+			By("rolling back to the first revision")
+			kc.Annotations = map[string]string{RollbackToAnnotation: "1"}
+			Expect(k8sClient.Update(ctx, kc)).To(Succeed())
+			kc = reconcileKconfig()
+			Expect(kc.Annotations).NotTo(HaveKey(RollbackToAnnotation))
+			kc = reconcileKconfig()
+			Expect(kc.Status.CurrentRevision).To(Equal(resourceName + "-rev-3"))
+			Expect(revision(resourceName + "-rev-3").Spec.Hash).To(Equal(first.Spec.Hash))
+
+			By("pruning beyond the history limit except for pinned revisions")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pinned-history",
+					Namespace:   "default",
+					Annotations: map[string]string{PinRevisionAnnotation: resourceName + "-rev-2"},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			}()
+			limit := int32(1)
+			value = "qux"
+			kc.Spec.HistoryLimit = &limit
+			kc.Spec.EnvConfigs = []kconfigcontrollerv1beta1.EnvConfig{{Type: ConfigMapEnvConfigType, Key: "FOO", Value: &value}}
+			Expect(k8sClient.Update(ctx, kc)).To(Succeed())
+			kc = reconcileKconfig()
+			Expect(kc.Status.CurrentRevision).To(Equal(resourceName + "-rev-4"))
+			revision(resourceName + "-rev-2")
+			for _, pruned := range []string{resourceName + "-rev-1", resourceName + "-rev-3"} {
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: pruned}, &kconfigcontrollerv1beta1.KconfigRevision{})
+				Expect(errors.IsNotFound(err)).To(BeTrue())
+			}
+
+			name, ok := PinnedKconfigName(resourceName + "-rev-2")
+			Expect(ok).To(BeTrue())
+			Expect(name).To(Equal(resourceName))
+			_, ok = PinnedKconfigName(first.Spec.Hash)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should not accept an existing revision secret it does not own", func() {
+			controllerReconciler := &KconfigReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			kc := &kconfigcontrollerv1beta1.Kconfig{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, kc)).To(Succeed())
+			name := resourceName + "-rev-9"
+			foreign := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Data:       map[string][]byte{"SECRET": []byte("other")},
+			}
+			Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, foreign)).To(Succeed())
+			}()
+
+			snapshot := []kconfigcontrollerv1beta1.EnvConfig{{Type: SecretEnvConfigType, Key: "SECRET", SecretKeyRef: &corev1.SecretKeySelector{Key: "SECRET"}}}
+			_, err := controllerReconciler.createRevision(ctx, kc, 9, "hash", snapshot, map[string][]byte{"SECRET": []byte("value")})
+			Expect(err).To(HaveOccurred())
+			// the revision is recorded again once the secret is out of the way
+			err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &kconfigcontrollerv1beta1.KconfigRevision{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+	Context("When pruning orphaned keys", func() {
+		ctx := context.Background()
+
+		It("should prune unreferenced keys after the grace period only", func() {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "kc-test-gc", Namespace: "default"},
+				Data: map[string]string{
+					"current":       "a",
+					"stable":        "b",
+					"stable-canary": "e",
+					"rollback":      "c",
+					"orphan":        "d",
+				},
+			}
+			Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, cm)).To(Succeed())
+			}()
+			ref := func(key string) []corev1.EnvVar {
+				return []corev1.EnvVar{{Name: "FOO", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+					Key:                  key,
+				}}}}
+			}
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-gc", Namespace: "default"},
+				Spec:       kconfigcontrollerv1beta1.KconfigBindingSpec{Envs: ref("current")},
+			}
+			Expect(k8sClient.Create(ctx, kcb)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, kcb)).To(Succeed())
+			}()
+			By("referencing keys from the stable and rolled back envs and canary of a binding")
+			kcb.Status.StableEnvs = ref("stable")
+			kcb.Status.StableCanary = &kconfigcontrollerv1beta1.Canary{Envs: ref("stable-canary"), Percent: 10}
+			kcb.Status.Rollback = &kconfigcontrollerv1beta1.RollbackStatus{
+				FailedConfigHash: "bad",
+				ConfigHash:       "good",
+				Envs:             ref("rollback"),
+				Time:             metav1.Now(),
+			}
+			Expect(k8sClient.Status().Update(ctx, kcb)).To(Succeed())
+
+			controllerReconciler := &KconfigReconciler{
+				Client:                 k8sClient,
+				Scheme:                 k8sClient.Scheme(),
+				ConfigMapPrefix:        "kc-",
+				SecretPrefix:           "kc-",
+				OrphanedKeyGracePeriod: time.Hour,
+			}
+			kc := &kconfigcontrollerv1beta1.Kconfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-gc", Namespace: "default"},
+				Spec: kconfigcontrollerv1beta1.KconfigSpec{
+					EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{{
+						Type:            ConfigMapEnvConfigType,
+						Key:             "FOO",
+						ConfigMapKeyRef: ref("current")[0].ValueFrom.ConfigMapKeyRef,
+					}},
+				},
+			}
+
+			By("keeping an orphaned key within the grace period")
+			result, err := controllerReconciler.pruneOrphanedKeys(ctx, kc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+			Expect(kc.Status.OrphanedKeys).To(HaveLen(1))
+			Expect(kc.Status.OrphanedKeys[0].Kind).To(Equal(ConfigMapEnvConfigType))
+			Expect(kc.Status.OrphanedKeys[0].Key).To(Equal("orphan"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+			Expect(cm.Data).To(HaveLen(5))
+
+			By("pruning it once the grace period expired")
+			kc.Status.OrphanedKeys[0].Since = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+			_, err = controllerReconciler.pruneOrphanedKeys(ctx, kc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kc.Status.OrphanedKeys).To(BeEmpty())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+			Expect(cm.Data).To(Equal(map[string]string{"current": "a", "stable": "b", "stable-canary": "e", "rollback": "c"}))
 		})
 	})
 })
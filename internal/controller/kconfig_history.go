@@ -0,0 +1,392 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigrevisions,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs;jobs,verbs=get;list;watch
+
+// historyName is the name of a KconfigRevision and of the Secret holding its secret values
+func historyName(kcName string, revision int64) string {
+	return fmt.Sprintf("%s-rev-%d", kcName, revision)
+}
+
+// resolveHistoryValues returns the envConfigs with references into the Secrets of KconfigRevisions,
+// as written by a rollback, replaced by their values
+func (r *KconfigReconciler) resolveHistoryValues(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, ecs []kconfigcontrollerv1beta1.EnvConfig) ([]kconfigcontrollerv1beta1.EnvConfig, error) {
+	prefix := kc.Name + "-rev-"
+	secrets := make(map[string]*v1.Secret)
+	resolved := make([]kconfigcontrollerv1beta1.EnvConfig, 0, len(ecs))
+	for _, ec := range ecs {
+		ec = *ec.DeepCopy()
+		if strings.ToLower(ec.Type) == "secret" && ec.Value == nil && ec.SecretKeyRef != nil && strings.HasPrefix(ec.SecretKeyRef.Name, prefix) {
+			sec, ok := secrets[ec.SecretKeyRef.Name]
+			if !ok {
+				sec = &v1.Secret{}
+				nn := types.NamespacedName{Namespace: kc.Namespace, Name: ec.SecretKeyRef.Name}
+				if err := r.Get(ctx, nn, sec); client.IgnoreNotFound(err) != nil {
+					return nil, fmt.Errorf("error getting kconfigRevision secret: %s", err.Error())
+				}
+				secrets[ec.SecretKeyRef.Name] = sec
+			}
+			value, ok := sec.Data[ec.SecretKeyRef.Key]
+			if ok && sec.Labels[KconfigNameLabel] == kc.Name {
+				stringValue := string(value)
+				ec.Value = &stringValue
+				ec.SecretKeyRef = nil
+			}
+		}
+		resolved = append(resolved, ec)
+	}
+	return resolved, nil
+}
+
+// recordRevision writes a KconfigRevision of the processed envConfigs if they differ from the latest
+// revision, and prunes revisions beyond the history limit that no pod or pod template is pinned to
+func (r *KconfigReconciler) recordRevision(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, ecs []kconfigcontrollerv1beta1.EnvConfig, cmName, secName string, cmActions, secActions []ExternalAction) error {
+	limit := int32(DefaultHistoryLimit)
+	if kc.Spec.HistoryLimit != nil {
+		limit = *kc.Spec.HistoryLimit
+	}
+	var revisions kconfigcontrollerv1beta1.KconfigRevisionList
+	if err := r.List(ctx, &revisions, client.InNamespace(kc.Namespace), client.MatchingLabels{KconfigNameLabel: kc.Name}); err != nil {
+		return fmt.Errorf("error getting kconfigRevisionList: %s", err.Error())
+	}
+	history := revisions.Items
+	sort.Slice(history, func(i, j int) bool { return history[i].Spec.Revision > history[j].Spec.Revision })
+	kc.Status.CurrentRevision = ""
+	if limit > 0 {
+		snapshot, secretValues, err := r.snapshotEnvConfigs(ctx, kc, ecs, cmName, secName, cmActions, secActions)
+		if err != nil {
+			return err
+		}
+		hash := snapshotHash(kc, snapshot, secretValues)
+		if len(history) == 0 || history[0].Spec.Hash != hash {
+			revision := int64(1)
+			if len(history) > 0 {
+				revision = history[0].Spec.Revision + 1
+			}
+			created, err := r.createRevision(ctx, kc, revision, hash, snapshot, secretValues)
+			if err != nil {
+				return err
+			}
+			history = append([]kconfigcontrollerv1beta1.KconfigRevision{*created}, history...)
+		}
+		kc.Status.CurrentRevision = history[0].Name
+	}
+	if len(history) <= int(limit) {
+		return nil
+	}
+	pinned := make(map[string]bool)
+	if err := r.visitPinsInUse(ctx, kc.Namespace, func(pin string) { pinned[pin] = true }); err != nil {
+		return err
+	}
+	for i := int(limit); i < len(history); i++ {
+		if pinned[history[i].Name] || pinned[history[i].Spec.Hash] {
+			continue
+		}
+		// the secret of a revision is garbage collected with it
+		if err := r.Delete(ctx, &history[i]); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting kconfigRevision: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// visitPinsInUse calls visit for every revision pin of pods and of the pod templates of workloads.
+// Pods yet to be created from a template are injected with the revisions it is pinned to.
+func (r *KconfigReconciler) visitPinsInUse(ctx context.Context, namespace string, visit func(pin string)) error {
+	visitPins := func(annotations map[string]string) {
+		for _, pin := range PinnedRevisions(annotations) {
+			visit(pin)
+		}
+	}
+
+	podList, err := r.listPods(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		visitPins(pod.Annotations)
+	}
+
+	var deploymentList appsv1.DeploymentList
+	if err := r.List(ctx, &deploymentList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting deploymentList: %s", err.Error())
+	}
+	for _, deployment := range deploymentList.Items {
+		visitPins(deployment.Spec.Template.Annotations)
+	}
+	var statefulSetList appsv1.StatefulSetList
+	if err := r.List(ctx, &statefulSetList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting statefulSetList: %s", err.Error())
+	}
+	for _, statefulSet := range statefulSetList.Items {
+		visitPins(statefulSet.Spec.Template.Annotations)
+	}
+	var daemonSetList appsv1.DaemonSetList
+	if err := r.List(ctx, &daemonSetList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting daemonSetList: %s", err.Error())
+	}
+	for _, daemonSet := range daemonSetList.Items {
+		visitPins(daemonSet.Spec.Template.Annotations)
+	}
+	var cronJobList batchv1.CronJobList
+	if err := r.List(ctx, &cronJobList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting cronJobList: %s", err.Error())
+	}
+	for _, cronJob := range cronJobList.Items {
+		visitPins(cronJob.Spec.JobTemplate.Spec.Template.Annotations)
+	}
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting jobList: %s", err.Error())
+	}
+	for _, job := range jobList.Items {
+		visitPins(job.Spec.Template.Annotations)
+	}
+	return nil
+}
+
+// snapshotEnvConfigs inlines the values of keys of the generated ConfigMap into the envConfigs and
+// returns the values of keys of the generated Secret by envConfig key, referenced from the envConfigs
+// under the name of the revision
+func (r *KconfigReconciler) snapshotEnvConfigs(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, ecs []kconfigcontrollerv1beta1.EnvConfig, cmName, secName string, cmActions, secActions []ExternalAction) ([]kconfigcontrollerv1beta1.EnvConfig, map[string][]byte, error) {
+	cmValues := make(map[string]string)
+	for _, action := range cmActions {
+		cmValues[action.Key] = action.Value
+	}
+	secValues := make(map[string][]byte)
+	for _, action := range secActions {
+		secValues[action.Key] = []byte(action.Value)
+	}
+	var cm *v1.ConfigMap
+	var sec *v1.Secret
+	snapshot := make([]kconfigcontrollerv1beta1.EnvConfig, 0, len(ecs))
+	secretValues := make(map[string][]byte)
+	for _, ec := range ecs {
+		ec = *ec.DeepCopy()
+		switch {
+		case ec.ConfigMapKeyRef != nil && ec.ConfigMapKeyRef.Name == cmName:
+			value, ok := cmValues[ec.ConfigMapKeyRef.Key]
+			if !ok {
+				if cm == nil {
+					cm = &v1.ConfigMap{}
+					if err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: cmName}, cm); err != nil {
+						return nil, nil, fmt.Errorf("error getting configmap: %s", err.Error())
+					}
+				}
+				if value, ok = cm.Data[ec.ConfigMapKeyRef.Key]; !ok {
+					return nil, nil, fmt.Errorf("key %s of %s not found in configmap", ec.ConfigMapKeyRef.Key, ec.Key)
+				}
+			}
+			ec.Value = &value
+			ec.ConfigMapKeyRef = nil
+		case ec.SecretKeyRef != nil && ec.SecretKeyRef.Name == secName:
+			value, ok := secValues[ec.SecretKeyRef.Key]
+			if !ok {
+				if sec == nil {
+					sec = &v1.Secret{}
+					if err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: secName}, sec); err != nil {
+						return nil, nil, fmt.Errorf("error getting secret: %s", err.Error())
+					}
+				}
+				if value, ok = sec.Data[ec.SecretKeyRef.Key]; !ok {
+					return nil, nil, fmt.Errorf("key %s of %s not found in secret", ec.SecretKeyRef.Key, ec.Key)
+				}
+			}
+			secretValues[ec.Key] = value
+			// the name of the revision is filled in once known
+			ec.SecretKeyRef = &v1.SecretKeySelector{Key: ec.Key}
+		}
+		snapshot = append(snapshot, ec)
+	}
+	return snapshot, secretValues, nil
+}
+
+// snapshotHash hashes a snapshot salted with the Kconfig UID, like valueHash
+func snapshotHash(kc *kconfigcontrollerv1beta1.Kconfig, snapshot []kconfigcontrollerv1beta1.EnvConfig, secretValues map[string][]byte) string {
+	hash := sha256.New()
+	write := func(field []byte) {
+		_ = binary.Write(hash, binary.BigEndian, uint64(len(field)))
+		hash.Write(field)
+	}
+	write([]byte(kc.UID))
+	spec, _ := json.Marshal(snapshot)
+	write(spec)
+	for _, key := range sortedKeys(secretValues) {
+		write([]byte(key))
+		write(secretValues[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:ConfigHashLength]
+}
+
+func (r *KconfigReconciler) createRevision(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, revision int64, hash string, snapshot []kconfigcontrollerv1beta1.EnvConfig, secretValues map[string][]byte) (*kconfigcontrollerv1beta1.KconfigRevision, error) {
+	name := historyName(kc.Name, revision)
+	for i := range snapshot {
+		if ref := snapshot[i].SecretKeyRef; ref != nil && ref.Name == "" {
+			ref.Name = name
+		}
+	}
+	rev := &kconfigcontrollerv1beta1.KconfigRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: kc.Namespace,
+			Name:      name,
+			Labels:    map[string]string{KconfigNameLabel: kc.Name},
+		},
+		Spec: kconfigcontrollerv1beta1.KconfigRevisionSpec{
+			KconfigName: kc.Name,
+			Revision:    revision,
+			Hash:        hash,
+			Author:      kconfigAuthor(kc),
+			Timestamp:   metav1.Now(),
+			EnvConfigs:  snapshot,
+		},
+	}
+	if err := controllerutil.SetControllerReference(kc, rev, r.Scheme); err != nil {
+		return nil, fmt.Errorf("error setting kconfigRevision owner: %s", err.Error())
+	}
+	if err := r.Create(ctx, rev); err != nil {
+		return nil, fmt.Errorf("error creating kconfigRevision: %s", err.Error())
+	}
+	if len(secretValues) == 0 {
+		return rev, nil
+	}
+	immutable := true
+	sec := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: kc.Namespace,
+			Name:      name,
+			Labels:    map[string]string{KconfigNameLabel: kc.Name},
+		},
+		Immutable: &immutable,
+		Data:      secretValues,
+	}
+	if err := controllerutil.SetControllerReference(rev, sec, r.Scheme); err != nil {
+		return nil, fmt.Errorf("error setting kconfigRevision secret owner: %s", err.Error())
+	}
+	if err := r.createRevisionSecret(ctx, rev, sec); err != nil {
+		// without its secret the revision can't be injected, it is recorded again on the next reconcile
+		if delErr := r.Delete(ctx, rev); delErr != nil && !errors.IsNotFound(delErr) {
+			return nil, fmt.Errorf("%s, error deleting kconfigRevision: %s", err.Error(), delErr.Error())
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+// createRevisionSecret creates the secret of a revision. An existing secret of the same name is only
+// accepted if it is owned by the revision and holds the same values, as it would otherwise be injected
+// into pods pinned to the revision in place of the recorded values.
+func (r *KconfigReconciler) createRevisionSecret(ctx context.Context, rev *kconfigcontrollerv1beta1.KconfigRevision, sec *v1.Secret) error {
+	err := r.Create(ctx, sec)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating kconfigRevision secret: %s", err.Error())
+	}
+	var existing v1.Secret
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sec), &existing); err != nil {
+		return fmt.Errorf("error getting existing kconfigRevision secret: %s", err.Error())
+	}
+	if !metav1.IsControlledBy(&existing, rev) {
+		return fmt.Errorf("secret %s already exists and is not owned by kconfigRevision %s", sec.Name, rev.Name)
+	}
+	if !equality.Semantic.DeepEqual(existing.Data, sec.Data) {
+		return fmt.Errorf("secret %s of kconfigRevision %s already exists with other values", sec.Name, rev.Name)
+	}
+	return nil
+}
+
+// kconfigAuthor returns the field manager that last changed the spec of the Kconfig, other than the controller
+func kconfigAuthor(kc *kconfigcontrollerv1beta1.Kconfig) string {
+	author := ""
+	var latest *metav1.Time
+	for _, entry := range kc.ManagedFields {
+		if entry.Subresource != "" || entry.Manager == FieldManager || entry.Time == nil || entry.FieldsV1 == nil {
+			continue
+		}
+		if !strings.Contains(string(entry.FieldsV1.Raw), `"f:spec"`) {
+			continue
+		}
+		if latest == nil || !entry.Time.Before(latest) {
+			author = entry.Manager
+			latest = entry.Time
+		}
+	}
+	return author
+}
+
+// applyRollback replaces the envConfigs of a Kconfig annotated with rollback-to with those of the
+// referenced KconfigRevision. It returns whether the Kconfig was updated.
+func (r *KconfigReconciler) applyRollback(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig) (bool, error) {
+	ref, ok := kc.Annotations[RollbackToAnnotation]
+	if !ok {
+		return false, nil
+	}
+	delete(kc.Annotations, RollbackToAnnotation)
+	name := ref
+	if revision, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		name = historyName(kc.Name, revision)
+	}
+	var rev kconfigcontrollerv1beta1.KconfigRevision
+	err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: name}, &rev)
+	switch {
+	case client.IgnoreNotFound(err) != nil:
+		return false, fmt.Errorf("error getting kconfigRevision: %s", err.Error())
+	case err != nil || rev.Spec.KconfigName != kc.Name:
+		r.Recorder.Eventf(kc, WarningEventType, RollbackFailedEvent, "kconfigRevision %s of this kconfig not found", name)
+	case r.isNonMutating(kc):
+		r.Recorder.Event(kc, WarningEventType, RollbackFailedEvent, "non-mutating kconfigs are rolled back at their source")
+	default:
+		kc.Spec.EnvConfigs = make([]kconfigcontrollerv1beta1.EnvConfig, 0, len(rev.Spec.EnvConfigs))
+		for _, ec := range rev.Spec.EnvConfigs {
+			kc.Spec.EnvConfigs = append(kc.Spec.EnvConfigs, *ec.DeepCopy())
+		}
+		r.Recorder.Eventf(kc, NormalEventType, RolledBackEvent, "rolled back to kconfigRevision %s", name)
+	}
+	if err := r.Update(ctx, kc, client.FieldOwner(FieldManager+"-rollback")); err != nil {
+		return false, fmt.Errorf("error updating kconfig: %s", err.Error())
+	}
+	return true, nil
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// pruneOrphanedKeys removes keys of the generated ConfigMap and Secret that have been referenced
+// neither by the Kconfig nor by any pod, binding or KconfigRevision for longer than OrphanedKeyGracePeriod.
+// Keys within the grace period are recorded in status and the Kconfig is requeued for when they expire.
+func (r *KconfigReconciler) pruneOrphanedKeys(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig) (ctrl.Result, error) {
+	// immutable revisions are never edited, they are retained by pruneRevisions instead
+	if r.OrphanedKeyGracePeriod <= 0 || kc.Spec.ImmutableRevisions {
+		kc.Status.OrphanedKeys = nil
+		return ctrl.Result{}, nil
+	}
+	cmName := fmt.Sprintf("%s%s", r.ConfigMapPrefix, kc.Name)
+	secName := fmt.Sprintf("%s%s", r.SecretPrefix, kc.Name)
+
+	cmKeys, secKeys := r.ownedKeys(kc)
+	referenced := map[string]map[string]bool{
+		ConfigMapEnvConfigType: toSet(cmKeys),
+		SecretEnvConfigType:    toSet(secKeys),
+	}
+	if err := r.addKeysInUse(ctx, kc.Namespace, cmName, secName, referenced); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	since := make(map[string]metav1.Time)
+	for _, orphan := range kc.Status.OrphanedKeys {
+		since[orphan.Kind+"/"+orphan.Key] = orphan.Since
+	}
+	now := metav1.Now()
+	orphans := make([]kconfigcontrollerv1beta1.OrphanedKey, 0)
+	var requeueAfter time.Duration
+	// prune returns true if key has expired, otherwise it is recorded as orphaned
+	prune := func(kind, key string) bool {
+		if referenced[kind][key] {
+			return false
+		}
+		orphanedSince, ok := since[kind+"/"+key]
+		if !ok {
+			orphanedSince = now
+		}
+		remaining := r.OrphanedKeyGracePeriod - now.Sub(orphanedSince.Time)
+		if remaining <= 0 {
+			return true
+		}
+		orphans = append(orphans, kconfigcontrollerv1beta1.OrphanedKey{Kind: kind, Key: key, Since: orphanedSince})
+		if requeueAfter == 0 || remaining < requeueAfter {
+			requeueAfter = remaining
+		}
+		return false
+	}
+
+	var cm v1.ConfigMap
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: cmName}, &cm); err == nil {
+		pruned := false
+		for _, key := range sortedKeys(cm.Data) {
+			if prune(ConfigMapEnvConfigType, key) {
+				delete(cm.Data, key)
+				pruned = true
+			}
+		}
+		if pruned {
+			if err := r.Update(ctx, &cm); err != nil {
+				return ctrl.Result{}, fmt.Errorf("error updating configmap: %s", err.Error())
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("error getting configmap: %s", err.Error())
+	}
+
+	var sec v1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: secName}, &sec); err == nil {
+		pruned := false
+		for _, key := range sortedKeys(sec.Data) {
+			if prune(SecretEnvConfigType, key) {
+				delete(sec.Data, key)
+				pruned = true
+			}
+		}
+		if pruned {
+			if err := r.Update(ctx, &sec); err != nil {
+				return ctrl.Result{}, fmt.Errorf("error updating secret: %s", err.Error())
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("error getting secret: %s", err.Error())
+	}
+
+	kc.Status.OrphanedKeys = orphans
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// addKeysInUse adds the keys of the named ConfigMap and Secret referenced by pods, bindings and KconfigRevisions
+func (r *KconfigReconciler) addKeysInUse(ctx context.Context, namespace, cmName, secName string, referenced map[string]map[string]bool) error {
+	return r.visitEnvInUse(ctx, namespace, func(env v1.EnvVar) {
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && ref.Name == cmName {
+			referenced[ConfigMapEnvConfigType][ref.Key] = true
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil && ref.Name == secName {
+			referenced[SecretEnvConfigType][ref.Key] = true
+		}
+	})
+}
+
+// visitEnvInUse calls visit for every env with a valueFrom source of pods, of the envs bindings inject
+// or roll back to, and of the KconfigRevisions that pods can be pinned to. Pods that are yet to be
+// created are injected with the envs of the bindings at admission.
+func (r *KconfigReconciler) visitEnvInUse(ctx context.Context, namespace string, visit func(env v1.EnvVar)) error {
+	visitEnvs := func(envs []v1.EnvVar) {
+		for _, env := range envs {
+			if env.ValueFrom != nil {
+				visit(env)
+			}
+		}
+	}
+
+	podList, err := r.listPods(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			visitEnvs(container.Env)
+		}
+	}
+
+	var kcbList kconfigcontrollerv1beta1.KconfigBindingList
+	if err := r.List(ctx, &kcbList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting kconfigBindingList: %s", err.Error())
+	}
+	for _, kcb := range kcbList.Items {
+		visitEnvs(kcb.Spec.Envs)
+		if kcb.Spec.Canary != nil {
+			visitEnvs(kcb.Spec.Canary.Envs)
+		}
+		visitEnvs(kcb.Status.StableEnvs)
+		if kcb.Status.StableCanary != nil {
+			visitEnvs(kcb.Status.StableCanary.Envs)
+		}
+		if kcb.Status.Rollback != nil {
+			visitEnvs(kcb.Status.Rollback.Envs)
+			if kcb.Status.Rollback.Canary != nil {
+				visitEnvs(kcb.Status.Rollback.Canary.Envs)
+			}
+		}
+		if kcb.Status.RolledOut != nil {
+			visitEnvs(kcb.Status.RolledOut.Envs)
+			if kcb.Status.RolledOut.Canary != nil {
+				visitEnvs(kcb.Status.RolledOut.Canary.Envs)
+			}
+		}
+	}
+
+	var revisionList kconfigcontrollerv1beta1.KconfigRevisionList
+	if err := r.List(ctx, &revisionList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error getting kconfigRevisionList: %s", err.Error())
+	}
+	for i := range revisionList.Items {
+		visitEnvs(RevisionEnvs(&revisionList.Items[i]))
+	}
+	return nil
+}
+
+// listPods lists the pods of a namespace through the PodReader
+func (r *KconfigReconciler) listPods(ctx context.Context, namespace string) (*v1.PodList, error) {
+	var reader client.Reader = r.Client
+	if r.PodReader != nil {
+		reader = r.PodReader
+	}
+	var podList v1.PodList
+	if err := reader.List(ctx, &podList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error getting podList: %s", err.Error())
+	}
+	return &podList, nil
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
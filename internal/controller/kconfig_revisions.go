@@ -0,0 +1,282 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// revisionName suffixes the generated object name with a hash of the data it will hold, salted with
+// the Kconfig UID like valueHash, so that the name does not confirm guessed secret values
+func revisionName(kc *kconfigcontrollerv1beta1.Kconfig, name string, actions []ExternalAction) string {
+	sorted := make([]ExternalAction, len(actions))
+	copy(sorted, actions)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	hash := sha256.New()
+	hash.Write([]byte(kc.UID))
+	for _, action := range sorted {
+		for _, field := range []string{action.Key, action.Value} {
+			_ = binary.Write(hash, binary.BigEndian, uint64(len(field)))
+			hash.Write([]byte(field))
+		}
+	}
+	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(hash.Sum(nil))[:RevisionHashLength])
+}
+
+func isRevisionName(name, prefix string) bool {
+	return strings.HasPrefix(name, prefix) && len(name) == len(prefix)+RevisionHashLength
+}
+
+// resolveRevisionValues returns the envConfigs with references into earlier revisions of this Kconfig
+// or into its mutable generated ConfigMap and Secret replaced by their values, so that envConfigs
+// carried over from an earlier revision or written before revisions were enabled are part of the next one
+func (r *KconfigReconciler) resolveRevisionValues(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, ecs []kconfigcontrollerv1beta1.EnvConfig) ([]kconfigcontrollerv1beta1.EnvConfig, error) {
+	cmName := fmt.Sprintf("%s%s", r.ConfigMapPrefix, kc.Name)
+	secName := fmt.Sprintf("%s%s", r.SecretPrefix, kc.Name)
+	generated := func(name, generatedName string) bool {
+		return name == generatedName || isRevisionName(name, generatedName+"-")
+	}
+	configMaps := make(map[string]*v1.ConfigMap)
+	secrets := make(map[string]*v1.Secret)
+	resolved := make([]kconfigcontrollerv1beta1.EnvConfig, 0, len(ecs))
+	for _, ec := range ecs {
+		ec = *ec.DeepCopy()
+		if ec.Value != nil || ec.EncryptedValue != nil {
+			resolved = append(resolved, ec)
+			continue
+		}
+		switch strings.ToLower(ec.Type) {
+		case "configmap":
+			if ec.ConfigMapKeyRef == nil || !generated(ec.ConfigMapKeyRef.Name, cmName) {
+				break
+			}
+			cm, ok := configMaps[ec.ConfigMapKeyRef.Name]
+			if !ok {
+				cm = &v1.ConfigMap{}
+				nn := types.NamespacedName{Namespace: kc.Namespace, Name: ec.ConfigMapKeyRef.Name}
+				if err := r.Get(ctx, nn, cm); err != nil {
+					return nil, fmt.Errorf("error getting configmap: %s", err.Error())
+				}
+				configMaps[ec.ConfigMapKeyRef.Name] = cm
+			}
+			if value, ok := cm.Data[ec.ConfigMapKeyRef.Key]; ok {
+				ec.Value = &value
+				ec.ConfigMapKeyRef = nil
+			}
+		case "secret":
+			if ec.SecretKeyRef == nil || !generated(ec.SecretKeyRef.Name, secName) {
+				break
+			}
+			sec, ok := secrets[ec.SecretKeyRef.Name]
+			if !ok {
+				sec = &v1.Secret{}
+				nn := types.NamespacedName{Namespace: kc.Namespace, Name: ec.SecretKeyRef.Name}
+				if err := r.Get(ctx, nn, sec); err != nil {
+					return nil, fmt.Errorf("error getting secret: %s", err.Error())
+				}
+				secrets[ec.SecretKeyRef.Name] = sec
+			}
+			if value, ok := sec.Data[ec.SecretKeyRef.Key]; ok {
+				stringValue := string(value)
+				ec.Value = &stringValue
+				ec.SecretKeyRef = nil
+			}
+		}
+		resolved = append(resolved, ec)
+	}
+	return resolved, nil
+}
+
+// renameRevisionRefs points references to the generated objects at their revision
+func renameRevisionRefs(envVars []v1.EnvVar, ecs []kconfigcontrollerv1beta1.EnvConfig, ecStatuses []kconfigcontrollerv1beta1.EnvConfigStatus, cmName, cmRevision, secName, secRevision string) {
+	rename := func(cmRef *v1.ConfigMapKeySelector, secRef *v1.SecretKeySelector) {
+		if cmRef != nil && cmRef.Name == cmName {
+			cmRef.Name = cmRevision
+		}
+		if secRef != nil && secRef.Name == secName {
+			secRef.Name = secRevision
+		}
+	}
+	for _, envVar := range envVars {
+		if envVar.ValueFrom != nil {
+			rename(envVar.ValueFrom.ConfigMapKeyRef, envVar.ValueFrom.SecretKeyRef)
+		}
+	}
+	for _, ec := range ecs {
+		rename(ec.ConfigMapKeyRef, ec.SecretKeyRef)
+	}
+	for _, ecStatus := range ecStatuses {
+		rename(ecStatus.ConfigMapKeyRef, ecStatus.SecretKeyRef)
+	}
+}
+
+func (r *KconfigReconciler) executeConfigMapRevision(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, name string, actions []ExternalAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	var cm v1.ConfigMap
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: name}, &cm); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("error getting configmap: %s", err.Error())
+	}
+	immutable := true
+	cm = v1.ConfigMap{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: kc.Namespace,
+			Name:      name,
+			Labels:    map[string]string{KconfigNameLabel: kc.Name},
+		},
+		Immutable: &immutable,
+		Data:      make(map[string]string),
+	}
+	for _, action := range actions {
+		cm.Data[action.Key] = action.Value
+	}
+	if err := controllerutil.SetControllerReference(kc, &cm, r.Scheme); err != nil {
+		return fmt.Errorf("error setting configmap owner: %s", err.Error())
+	}
+	if err := r.Create(ctx, &cm); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating configmap: %s", err.Error())
+	}
+	return nil
+}
+
+func (r *KconfigReconciler) executeSecretRevision(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, name string, actions []ExternalAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	var sec v1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kc.Namespace, Name: name}, &sec); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("error getting secret: %s", err.Error())
+	}
+	immutable := true
+	sec = v1.Secret{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: kc.Namespace,
+			Name:      name,
+			Labels:    map[string]string{KconfigNameLabel: kc.Name},
+		},
+		Immutable: &immutable,
+		Data:      make(map[string][]byte),
+	}
+	for _, action := range actions {
+		sec.Data[action.Key] = []byte(action.Value)
+	}
+	if err := controllerutil.SetControllerReference(kc, &sec, r.Scheme); err != nil {
+		return fmt.Errorf("error setting secret owner: %s", err.Error())
+	}
+	if err := r.Create(ctx, &sec); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating secret: %s", err.Error())
+	}
+	return nil
+}
+
+// pruneRevisions deletes revisions of this Kconfig beyond the revision history limit, keeping the
+// current revisions and any revision still referenced by a pod, binding or KconfigRevision
+func (r *KconfigReconciler) pruneRevisions(ctx context.Context, kc *kconfigcontrollerv1beta1.Kconfig, cmRevision, secRevision string) error {
+	limit := DefaultRevisionHistoryLimit
+	if kc.Spec.RevisionHistoryLimit != nil {
+		limit = int(*kc.Spec.RevisionHistoryLimit)
+	}
+	inUse := map[string]map[string]bool{
+		ConfigMapEnvConfigType: {cmRevision: true},
+		SecretEnvConfigType:    {secRevision: true},
+	}
+	if err := r.visitEnvInUse(ctx, kc.Namespace, func(env v1.EnvVar) {
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+			inUse[ConfigMapEnvConfigType][ref.Name] = true
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+			inUse[SecretEnvConfigType][ref.Name] = true
+		}
+	}); err != nil {
+		return err
+	}
+
+	var cmList v1.ConfigMapList
+	if err := r.List(ctx, &cmList, client.InNamespace(kc.Namespace), client.MatchingLabels{KconfigNameLabel: kc.Name}); err != nil {
+		return fmt.Errorf("error getting configMapList: %s", err.Error())
+	}
+	configMaps := make([]client.Object, 0, len(cmList.Items))
+	for i := range cmList.Items {
+		configMaps = append(configMaps, &cmList.Items[i])
+	}
+	for _, cm := range expiredRevisions(kc, configMaps, limit, inUse[ConfigMapEnvConfigType]) {
+		if err := r.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting configmap revision: %s", err.Error())
+		}
+	}
+
+	var secList v1.SecretList
+	if err := r.List(ctx, &secList, client.InNamespace(kc.Namespace), client.MatchingLabels{KconfigNameLabel: kc.Name}); err != nil {
+		return fmt.Errorf("error getting secretList: %s", err.Error())
+	}
+	secrets := make([]client.Object, 0, len(secList.Items))
+	for i := range secList.Items {
+		secrets = append(secrets, &secList.Items[i])
+	}
+	for _, sec := range expiredRevisions(kc, secrets, limit, inUse[SecretEnvConfigType]) {
+		if err := r.Delete(ctx, sec); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting secret revision: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// expiredRevisions returns the revisions owned by the Kconfig that are older than the newest limit
+// revisions and not in use
+func expiredRevisions(kc *kconfigcontrollerv1beta1.Kconfig, revisions []client.Object, limit int, inUse map[string]bool) []client.Object {
+	owned := make([]client.Object, 0, len(revisions))
+	for _, revision := range revisions {
+		if isOwnedBy(revision.GetOwnerReferences(), kc.UID) {
+			owned = append(owned, revision)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		ti, tj := owned[i].GetCreationTimestamp(), owned[j].GetCreationTimestamp()
+		if !ti.Equal(&tj) {
+			return tj.Before(&ti)
+		}
+		return owned[i].GetName() > owned[j].GetName()
+	})
+	expired := make([]client.Object, 0)
+	for i, revision := range owned {
+		if i < limit || inUse[revision.GetName()] {
+			continue
+		}
+		expired = append(expired, revision)
+	}
+	return expired
+}
@@ -18,16 +18,32 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"strconv"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
 )
@@ -35,14 +51,22 @@ import (
 // KconfigBindingReconciler reconciles a KconfigBinding object
 type KconfigBindingReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// JobRefreshPolicy is the default for Jobs that do not set the job-refresh-policy annotation
+	JobRefreshPolicy string
+	// WorkloadKinds are additional workload kinds embedding a pod template, handled as unstructured objects
+	WorkloadKinds []WorkloadKind
 }
 
 // +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigbindings,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigbindings/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigbindings/finalizers,verbs=update
-// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -67,94 +91,347 @@ func (r *KconfigBindingReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	if val, ok := kcb.Annotations[KconfigDisableTemplateRefresh]; ok {
 		disableTemplateRefresh = val
 	}
-	if kcb.Status.ObservedGeneration != kcb.Generation && disableTemplateRefresh != "true" {
-		err := r.processKconfigBinding(ctx, kcb)
-		if err != nil {
-			return ctrl.Result{}, fmt.Errorf("error processing kconfigBinding: %s", err.Error())
+	if !kcb.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&kcb, KconfigBindingRolloutFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if disableTemplateRefresh != "true" {
+			status := kcb.Status.DeepCopy()
+			done, result, err := r.removeBindingAnnotations(ctx, &kcb)
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("error rolling out removed kconfigBinding: %s", err.Error())
+			}
+			if !done {
+				if !equality.Semantic.DeepEqual(status, &kcb.Status) {
+					if err := r.Status().Update(ctx, &kcb); err != nil {
+						return ctrl.Result{}, fmt.Errorf("error updating kconfigBinding status: %s", err.Error())
+					}
+				}
+				return result, nil
+			}
+		}
+		controllerutil.RemoveFinalizer(&kcb, KconfigBindingRolloutFinalizer)
+		if err := r.Update(ctx, &kcb); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error removing kconfigBinding finalizer: %s", err.Error())
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(&kcb, KconfigBindingRolloutFinalizer) {
+		if err := r.Update(ctx, &kcb); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error adding kconfigBinding finalizer: %s", err.Error())
 		}
-		//kcbCopy := kcb.DeepCopy()
+	}
+	if disableTemplateRefresh == "true" {
+		return ctrl.Result{}, nil
+	}
+	status := kcb.Status.DeepCopy()
+	hash, err := r.configHash(ctx, &kcb, kcb.Spec.Envs)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error computing config hash: %s", err.Error())
+	}
+	if kcb.Spec.Canary != nil {
+		if hash, err = r.canaryConfigHash(ctx, &kcb, hash, kcb.Spec.Canary); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error computing canary config hash: %s", err.Error())
+		}
+	}
+	// a rollback holds until the failed config is replaced
+	if kcb.Status.Rollback != nil && kcb.Status.Rollback.FailedConfigHash != hash {
+		kcb.Status.Rollback = nil
+	}
+	if kcb.Status.Rollback != nil {
+		if hash, err = r.configHash(ctx, &kcb, kcb.Status.Rollback.Envs); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error computing rollback config hash: %s", err.Error())
+		}
+		if kcb.Status.Rollback.Canary != nil {
+			if hash, err = r.canaryConfigHash(ctx, &kcb, hash, kcb.Status.Rollback.Canary); err != nil {
+				return ctrl.Result{}, fmt.Errorf("error computing rollback canary config hash: %s", err.Error())
+			}
+		}
+	}
+	// workloads are brought up to date on every reconcile, as they may have started matching since
+	result, err := r.processKconfigBinding(ctx, &kcb, hash)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error processing kconfigBinding: %s", err.Error())
+	}
+	healthResult, err := r.checkRolloutHealth(ctx, &kcb, hash)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error checking rollout health: %s", err.Error())
+	}
+	result = earliestResult(result, healthResult)
+	// referenced ConfigMaps and Secrets can change without a new generation of the binding
+	if kcb.Status.ObservedGeneration != kcb.Generation || !equality.Semantic.DeepEqual(status, &kcb.Status) {
 		kcb.Status.ObservedGeneration = kcb.Generation
 		if err := r.Status().Update(ctx, &kcb); err != nil {
 			return ctrl.Result{}, fmt.Errorf("error updating kconfigBinding status: %s", err.Error())
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KconfigBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &kconfigcontrollerv1beta1.KconfigBinding{}, ConfigMapRefsField, func(obj client.Object) []string {
+		return referencedNames(obj.(*kconfigcontrollerv1beta1.KconfigBinding), func(source *corev1.EnvVarSource) string {
+			if source.ConfigMapKeyRef == nil {
+				return ""
+			}
+			return source.ConfigMapKeyRef.Name
+		})
+	}); err != nil {
+		return fmt.Errorf("error indexing kconfigBinding configmap references: %s", err.Error())
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &kconfigcontrollerv1beta1.KconfigBinding{}, SecretRefsField, func(obj client.Object) []string {
+		return referencedNames(obj.(*kconfigcontrollerv1beta1.KconfigBinding), func(source *corev1.EnvVarSource) string {
+			if source.SecretKeyRef == nil {
+				return ""
+			}
+			return source.SecretKeyRef.Name
+		})
+	}); err != nil {
+		return fmt.Errorf("error indexing kconfigBinding secret references: %s", err.Error())
+	}
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&kconfigcontrollerv1beta1.KconfigBinding{}).
-		Named("kconfigbinding").
-		Complete(r)
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.bindingsReferencing(ConfigMapRefsField))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.bindingsReferencing(SecretRefsField))).
+		Watches(&v1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.bindingsForWorkload), workloadPredicates).
+		Watches(&v1.StatefulSet{}, handler.EnqueueRequestsFromMapFunc(r.bindingsForWorkload), workloadPredicates).
+		Watches(&v1.DaemonSet{}, handler.EnqueueRequestsFromMapFunc(r.bindingsForWorkload), workloadPredicates).
+		Watches(&batchv1.CronJob{}, handler.EnqueueRequestsFromMapFunc(r.bindingsForWorkload), workloadPredicates).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(r.bindingsForWorkload), workloadPredicates).
+		Named("kconfigbinding")
+	for _, kind := range r.WorkloadKinds {
+		bldr = bldr.Watches(kind.newObject(), handler.EnqueueRequestsFromMapFunc(r.bindingsForWorkload), workloadPredicates)
+	}
+	return bldr.Complete(r)
 }
 
-func (r *KconfigBindingReconciler) processKconfigBinding(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding) error {
-	if err := r.updateDeployments(ctx, kcb); err != nil {
-		return fmt.Errorf("error updating deployments: %s", err.Error())
+// referencedNames returns the distinct object names the binding envs reference through nameOf,
+// including the envs of its canary and those recorded as stable or rolled back to, which are injected
+// on rollback, and those last rolled out, which are injected outside of maintenance windows
+func referencedNames(kcb *kconfigcontrollerv1beta1.KconfigBinding, nameOf func(source *corev1.EnvVarSource) string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	envs := append(make([]corev1.EnvVar, 0, len(kcb.Spec.Envs)), kcb.Spec.Envs...)
+	if kcb.Spec.Canary != nil {
+		envs = append(envs, kcb.Spec.Canary.Envs...)
 	}
-	if err := r.updateStatefulSets(ctx, kcb); err != nil {
-		return fmt.Errorf("error updating statefulsets: %s", err.Error())
+	envs = append(envs, kcb.Status.StableEnvs...)
+	if kcb.Status.StableCanary != nil {
+		envs = append(envs, kcb.Status.StableCanary.Envs...)
 	}
-	return nil
-}
-
-func (r *KconfigBindingReconciler) updateDeployments(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding) error {
-	var deploymentsList v1.DeploymentList
-	if err := r.List(ctx, &deploymentsList, client.InNamespace(kcb.Namespace)); err != nil {
-		return fmt.Errorf("error getting deploymentList: %s", err.Error())
+	if kcb.Status.Rollback != nil {
+		envs = append(envs, kcb.Status.Rollback.Envs...)
+		if kcb.Status.Rollback.Canary != nil {
+			envs = append(envs, kcb.Status.Rollback.Canary.Envs...)
+		}
 	}
-	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
-	if err != nil {
-		return fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	if kcb.Status.RolledOut != nil {
+		envs = append(envs, kcb.Status.RolledOut.Envs...)
+		if kcb.Status.RolledOut.Canary != nil {
+			envs = append(envs, kcb.Status.RolledOut.Canary.Envs...)
+		}
 	}
-	for _, deployment := range deploymentsList.Items {
-		if deployment.Annotations == nil || deployment.Annotations[AllowTemplateUpdatesAnnotation] != "true" {
+	for _, env := range envs {
+		if env.ValueFrom == nil {
 			continue
 		}
-		if !selector.Matches(labels.Set(deployment.Spec.Template.Labels)) {
-			continue
+		if name := nameOf(env.ValueFrom); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
 		}
-		deploymentCopy := deployment.DeepCopy()
-		if deploymentCopy.Spec.Template.Annotations == nil {
-			deploymentCopy.Spec.Template.Annotations = make(map[string]string)
+	}
+	return names
+}
+
+// bindingsReferencing maps a ConfigMap or Secret to the bindings in its namespace referencing it by the given index field
+func (r *KconfigBindingReconciler) bindingsReferencing(field string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var kcbs kconfigcontrollerv1beta1.KconfigBindingList
+		if err := r.List(ctx, &kcbs, client.InNamespace(obj.GetNamespace()), client.MatchingFields{field: obj.GetName()}); err != nil {
+			r.Log.Error(err, "error listing kconfigBindings referencing object", "object", client.ObjectKeyFromObject(obj))
+			return nil
 		}
-		generationAnnotation := fmt.Sprintf("%s%s-%s", GenerationAnnotationPrefix, kcb.Name, "generation")
-		kcbGenerationString := strconv.FormatInt(kcb.Generation, 10)
-		deploymentCopy.Spec.Template.Annotations[generationAnnotation] = kcbGenerationString
-		if err := r.Update(ctx, deploymentCopy); err != nil {
-			return fmt.Errorf("error updating deployment: %s", err.Error())
+		requests := make([]reconcile.Request, 0, len(kcbs.Items))
+		for _, kcb := range kcbs.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&kcb)})
 		}
+		return requests
 	}
-	return nil
 }
 
-func (r *KconfigBindingReconciler) updateStatefulSets(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding) error {
-	var statefulSetList v1.StatefulSetList
-	if err := r.List(ctx, &statefulSetList, client.InNamespace(kcb.Namespace)); err != nil {
-		return fmt.Errorf("error getting statefulSetList: %s", err.Error())
+// workloadPredicate passes workload changes that can affect which bindings apply to it, i.e. changes to
+// its template labels or its opt-in annotation, but not status updates
+var workloadPredicate = predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})
+
+var workloadPredicates = builder.WithPredicates(workloadPredicate)
+
+// bindingsForWorkload maps an opted-in workload to the bindings selecting its pod template and the
+// bindings it still carries a config hash annotation of
+func (r *KconfigBindingReconciler) bindingsForWorkload(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetAnnotations()[AllowTemplateUpdatesAnnotation] != "true" {
+		return nil
 	}
-	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
-	if err != nil {
-		return fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	template := r.templateOf(obj)
+	if template == nil {
+		return nil
 	}
-	for _, statefulSet := range statefulSetList.Items {
-		if statefulSet.Annotations == nil || statefulSet.Annotations[AllowTemplateUpdatesAnnotation] != "true" {
+	var kcbs kconfigcontrollerv1beta1.KconfigBindingList
+	if err := r.List(ctx, &kcbs, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "error listing kconfigBindings for workload", "workload", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, kcb := range kcbs.Items {
+		_, annotated := template.Annotations[ConfigHashAnnotation(kcb.Name)]
+		selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+		if err != nil {
 			continue
 		}
-		if !selector.Matches(labels.Set(statefulSet.Spec.Template.Labels)) {
+		if annotated || selector.Matches(labels.Set(template.Labels)) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&kcb)})
+		}
+	}
+	return requests
+}
+
+func (r *KconfigBindingReconciler) configHash(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding, envs []corev1.EnvVar) (string, error) {
+	return ConfigHash(ctx, r.Client, kcb, envs)
+}
+
+// ConfigHash hashes the binding envs together with the current values of the ConfigMap and Secret
+// keys they reference, so that the hash changes whenever the env a pod would receive changes. The
+// hash is salted with the binding UID, as it is readable from pod templates but covers secret values.
+func ConfigHash(ctx context.Context, c client.Reader, kcb *kconfigcontrollerv1beta1.KconfigBinding, envs []corev1.EnvVar) (string, error) {
+	namespace := kcb.Namespace
+	hash := sha256.New()
+	write := func(field string) {
+		_ = binary.Write(hash, binary.BigEndian, uint64(len(field)))
+		hash.Write([]byte(field))
+	}
+	write(string(kcb.UID))
+	configMaps := make(map[string]*corev1.ConfigMap)
+	secrets := make(map[string]*corev1.Secret)
+	for _, env := range envs {
+		spec, err := json.Marshal(env)
+		if err != nil {
+			return "", fmt.Errorf("error encoding env %s: %s", env.Name, err.Error())
+		}
+		write(string(spec))
+		if env.ValueFrom == nil {
 			continue
 		}
-		statefulSetCopy := statefulSet.DeepCopy()
-		if statefulSetCopy.Spec.Template.Annotations == nil {
-			statefulSetCopy.Spec.Template.Annotations = make(map[string]string)
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+			cm, ok := configMaps[ref.Name]
+			if !ok {
+				cm = &corev1.ConfigMap{}
+				if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, cm); client.IgnoreNotFound(err) != nil {
+					return "", fmt.Errorf("error getting configmap: %s", err.Error())
+				}
+				configMaps[ref.Name] = cm
+			}
+			value, ok := cm.Data[ref.Key]
+			write(strconv.FormatBool(ok))
+			write(value)
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+			sec, ok := secrets[ref.Name]
+			if !ok {
+				sec = &corev1.Secret{}
+				if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, sec); client.IgnoreNotFound(err) != nil {
+					return "", fmt.Errorf("error getting secret: %s", err.Error())
+				}
+				secrets[ref.Name] = sec
+			}
+			value, ok := sec.Data[ref.Key]
+			write(strconv.FormatBool(ok))
+			write(string(value))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:ConfigHashLength], nil
+}
+
+// canaryConfigHash combines the config hash of the envs with that of the canary envs and its
+// bucketing, so that pods are rolled when a canary starts, changes, is promoted or aborted
+func (r *KconfigBindingReconciler) canaryConfigHash(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding, hash string, canary *kconfigcontrollerv1beta1.Canary) (string, error) {
+	candidate, err := r.configHash(ctx, kcb, canary.Envs)
+	if err != nil {
+		return "", err
+	}
+	combined := sha256.Sum256([]byte(strings.Join([]string{hash, candidate, strconv.Itoa(int(canary.Percent)), canary.BucketBy}, "/")))
+	return hex.EncodeToString(combined[:])[:ConfigHashLength], nil
+}
+
+// InjectedEnvs returns the envs and canary a binding injects into pods at now, i.e. those it was rolled
+// back to while it is rolled back, and those last rolled out while its rollout is held back outside of
+// its maintenance windows
+func InjectedEnvs(kcb *kconfigcontrollerv1beta1.KconfigBinding, now time.Time) ([]corev1.EnvVar, *kconfigcontrollerv1beta1.Canary) {
+	if kcb.Status.Rollback != nil {
+		return kcb.Status.Rollback.Envs, kcb.Status.Rollback.Canary
+	}
+	if kcb.Status.RolledOut != nil && rolloutHeldBack(kcb, now) {
+		return kcb.Status.RolledOut.Envs, kcb.Status.RolledOut.Canary
+	}
+	return kcb.Spec.Envs, kcb.Spec.Canary
+}
+
+// ConfigHashAnnotation is the pod template annotation holding the config hash of a binding
+func ConfigHashAnnotation(kcbName string) string {
+	return ConfigHashAnnotationPrefix + annotationName(kcbName)
+}
+
+// CanaryVariantAnnotation is the pod annotation recording the canary variant injected for a binding
+func CanaryVariantAnnotation(kcbName string) string {
+	return CanaryVariantAnnotationPrefix + annotationName(kcbName)
+}
+
+// InjectedConfigAnnotation is the pod annotation holding the config hash of the envs of a binding
+// injected into the pod
+func InjectedConfigAnnotation(kcbName string) string {
+	return InjectedConfigAnnotationPrefix + annotationName(kcbName)
+}
+
+// annotationName shortens a binding name to fit the name part of an annotation
+func annotationName(kcbName string) string {
+	// annotation names are limited to 63 characters
+	if len(kcbName) > 63 {
+		sum := sha256.Sum256([]byte(kcbName))
+		kcbName = kcbName[:52] + "-" + hex.EncodeToString(sum[:])[:10]
+	}
+	return kcbName
+}
+
+// syncConfigHashAnnotation sets the config hash annotation of the binding on a pod template, or removes
+// it when hash is empty, and drops the generation annotations written by earlier versions. It returns
+// whether the template changed.
+func syncConfigHashAnnotation(template *corev1.PodTemplateSpec, kcbName, hash string) bool {
+	changed := false
+	for _, legacy := range []string{
+		fmt.Sprintf("%s%s-%s", GenerationAnnotationPrefix, kcbName, "generation"),
+		fmt.Sprintf("%s%s", GenerationAnnotationPrefix, kcbName),
+	} {
+		// generation annotations hold a generation, which tells them apart from the annotations of the
+		// same prefix that configure the pod, e.g. the inject opt-in for a binding named inject
+		value, ok := template.Annotations[legacy]
+		if _, err := strconv.ParseInt(value, 10, 64); ok && err == nil {
+			delete(template.Annotations, legacy)
+			changed = true
 		}
-		generationAnnotation := fmt.Sprintf("%s%s", GenerationAnnotationPrefix, kcb.Name)
-		statefulSetCopy.Spec.Template.Annotations[generationAnnotation] = fmt.Sprint(kcb.Generation)
-		if err := r.Update(ctx, statefulSetCopy); err != nil {
-			return fmt.Errorf("error updating statefulSet: %s", err.Error())
+	}
+	annotation := ConfigHashAnnotation(kcbName)
+	current, ok := template.Annotations[annotation]
+	switch {
+	case hash == "" && ok:
+		delete(template.Annotations, annotation)
+		changed = true
+	case hash != "" && current != hash:
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
 		}
+		template.Annotations[annotation] = hash
+		changed = true
 	}
-	return nil
+	return changed
 }
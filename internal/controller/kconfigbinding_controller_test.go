@@ -18,14 +18,23 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
 )
@@ -65,6 +74,14 @@ var _ = Describe("KconfigBinding Controller", func() {
 
 			By("Cleanup the specific resource instance KconfigBinding")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			controllerReconciler := &KconfigBindingReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
@@ -77,8 +94,610 @@ var _ = Describe("KconfigBinding Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Recording the config hash in status")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, kconfigbinding)).To(Succeed())
+			Expect(kconfigbinding.Status.ObservedGeneration).To(Equal(kconfigbinding.Generation))
+			Expect(kconfigbinding.Status.ConfigHash).To(HaveLen(ConfigHashLength))
+		})
+
+		It("should replace generation annotations with the config hash annotation", func() {
+			template := &corev1.PodTemplateSpec{}
+			template.Annotations = map[string]string{
+				GenerationAnnotationPrefix + resourceName + "-generation": "1",
+			}
+			Expect(syncConfigHashAnnotation(template, resourceName, "abc")).To(BeTrue())
+			Expect(template.Annotations).To(Equal(map[string]string{ConfigHashAnnotationPrefix + resourceName: "abc"}))
+			Expect(syncConfigHashAnnotation(template, resourceName, "abc")).To(BeFalse())
+			Expect(syncConfigHashAnnotation(template, resourceName, "")).To(BeTrue())
+			Expect(template.Annotations).To(BeEmpty())
+
+			By("keeping an annotation of the same form that is no generation")
+			template.Annotations = map[string]string{InjectConfigAnnotation: "true"}
+			Expect(syncConfigHashAnnotation(template, "inject", "abc")).To(BeTrue())
+			Expect(template.Annotations).To(HaveKeyWithValue(InjectConfigAnnotation, "true"))
+		})
+
+		It("should index the configmaps referenced by the binding envs", func() {
+			ref := func(name string) *corev1.EnvVarSource {
+				return &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				}}
+			}
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{}
+			kcb.Spec.Envs = []corev1.EnvVar{
+				{Name: "A", ValueFrom: ref("cm-a")},
+				{Name: "B", ValueFrom: ref("cm-a")},
+				{Name: "C", ValueFrom: ref("cm-b")},
+				{Name: "D", Value: "d"},
+			}
+			kcb.Status.StableEnvs = []corev1.EnvVar{{Name: "A", ValueFrom: ref("cm-stable")}}
+			kcb.Status.Rollback = &kconfigcontrollerv1beta1.RollbackStatus{
+				Envs:   []corev1.EnvVar{{Name: "A", ValueFrom: ref("cm-a")}},
+				Canary: &kconfigcontrollerv1beta1.Canary{Envs: []corev1.EnvVar{{Name: "A", ValueFrom: ref("cm-rollback-canary")}}},
+			}
+			names := referencedNames(kcb, func(source *corev1.EnvVarSource) string {
+				if source.ConfigMapKeyRef == nil {
+					return ""
+				}
+				return source.ConfigMapKeyRef.Name
+			})
+			Expect(names).To(Equal([]string{"cm-a", "cm-b", "cm-stable", "cm-rollback-canary"}))
+		})
+
+		It("should enqueue the bindings of a newly matching workload", func() {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "enqueue"}}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+			binding := func(name, app string) *kconfigcontrollerv1beta1.KconfigBinding {
+				kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.Name},
+					Spec: kconfigcontrollerv1beta1.KconfigBindingSpec{
+						Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+					},
+				}
+				Expect(k8sClient.Create(ctx, kcb)).To(Succeed())
+				return kcb
+			}
+			for _, kcb := range []*kconfigcontrollerv1beta1.KconfigBinding{
+				binding("matching", "enqueue-app"),
+				binding("other", "other-app"),
+				binding("annotated", "other-app"),
+			} {
+				defer func() {
+					Expect(k8sClient.Delete(ctx, kcb)).To(Succeed())
+				}()
+			}
+			template := corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "enqueue-app"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+			}
+			controllerReconciler := &KconfigBindingReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := func(name string) reconcile.Request {
+				return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace.Name, Name: name}}
+			}
+
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "app",
+					Namespace:   namespace.Name,
+					Annotations: map[string]string{AllowTemplateUpdatesAnnotation: "true"},
+				},
+				Spec: appsv1.DeploymentSpec{Template: *template.DeepCopy()},
+			}
+			deployment.Spec.Template.Annotations = map[string]string{ConfigHashAnnotation("annotated"): "old"}
+			Expect(controllerReconciler.bindingsForWorkload(ctx, deployment)).To(ConsistOf(request("matching"), request("annotated")))
+
+			statefulSet := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "app",
+					Namespace:   namespace.Name,
+					Annotations: map[string]string{AllowTemplateUpdatesAnnotation: "true"},
+				},
+				Spec: appsv1.StatefulSetSpec{Template: *template.DeepCopy()},
+			}
+			Expect(controllerReconciler.bindingsForWorkload(ctx, statefulSet)).To(ConsistOf(request("matching")))
+
+			By("not enqueueing for workloads that are not opted in or not matching")
+			statefulSet.Annotations = nil
+			Expect(controllerReconciler.bindingsForWorkload(ctx, statefulSet)).To(BeEmpty())
+			deployment.Spec.Template.Labels = map[string]string{"app": "unbound"}
+			deployment.Spec.Template.Annotations = nil
+			Expect(controllerReconciler.bindingsForWorkload(ctx, deployment)).To(BeEmpty())
+
+			By("passing creations and spec or annotation changes, but not status updates")
+			Expect(workloadPredicate.Create(event.CreateEvent{Object: deployment})).To(BeTrue())
+			updated := deployment.DeepCopy()
+			updated.Status.ReadyReplicas = 1
+			Expect(workloadPredicate.Update(event.UpdateEvent{ObjectOld: deployment, ObjectNew: updated})).To(BeFalse())
+			updated.Generation++
+			Expect(workloadPredicate.Update(event.UpdateEvent{ObjectOld: deployment, ObjectNew: updated})).To(BeTrue())
+			updated = deployment.DeepCopy()
+			updated.Annotations = map[string]string{AllowTemplateUpdatesAnnotation: "false"}
+			Expect(workloadPredicate.Update(event.UpdateEvent{ObjectOld: deployment, ObjectNew: updated})).To(BeTrue())
+		})
+
+		It("should strip generated fields from a recreated job", func() {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "job",
+					Namespace:       "default",
+					UID:             "uid",
+					ResourceVersion: "1",
+					Labels:          map[string]string{"app": "job", "batch.kubernetes.io/controller-uid": "uid"},
+					Annotations:     map[string]string{AllowTemplateUpdatesAnnotation: "true"},
+				},
+				Spec: batchv1.JobSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "uid"}},
+					Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+						"app":                                "job",
+						"batch.kubernetes.io/controller-uid": "uid",
+						"batch.kubernetes.io/job-name":       "job",
+					}}},
+				},
+			}
+			recreated := recreatedJob(job)
+			Expect(recreated.UID).To(BeEmpty())
+			Expect(recreated.ResourceVersion).To(BeEmpty())
+			Expect(recreated.Spec.Selector).To(BeNil())
+			Expect(recreated.Spec.Template.Labels).To(Equal(map[string]string{"app": "job"}))
+			Expect(job.Spec.Template.Labels).To(HaveLen(3))
+			Expect(recreated.Labels).To(Equal(map[string]string{"app": "job"}))
+			Expect(recreated.Annotations).To(HaveKeyWithValue(RecreatedJobAnnotation, "job"))
+			Expect(job.Labels).To(HaveLen(2))
+			Expect(job.Annotations).NotTo(HaveKey(RecreatedJobAnnotation))
+
+			By("naming replacements after the original job and their config")
+			name := recreatedJobName(recreated)
+			Expect(name).To(HavePrefix("job-"))
+			Expect(recreatedJobName(recreatedJob(recreated))).To(Equal(name))
+			Expect(syncConfigHashAnnotation(&recreated.Spec.Template, "kcb", "hash")).To(BeTrue())
+			replacement := recreatedJobName(recreated)
+			Expect(replacement).To(HavePrefix("job-"))
+			Expect(replacement).NotTo(Equal(name))
+			recreated.Name = replacement
+			Expect(recreatedJob(recreated).Annotations).To(HaveKeyWithValue(RecreatedJobAnnotation, "job"))
+		})
+
+		It("should sync the config hash annotation of a configured workload kind", func() {
+			kinds, err := ParseWorkloadKindsConfig([]byte(`
+workloadKinds:
+- group: argoproj.io
+  version: v1alpha1
+  kind: Rollout
+  podTemplatePath: .spec.template
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kinds).To(HaveLen(1))
+			obj := kinds[0].newObject()
+			obj.Object["spec"] = map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "test"}},
+				},
+			}
+			template, err := kinds[0].podTemplate(obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(template.Labels).To(Equal(map[string]string{"app": "test"}))
+			Expect(syncConfigHashAnnotation(template, "kcb", "hash")).To(BeTrue())
+			Expect(kinds[0].setPodTemplateAnnotations(obj, template.Annotations)).To(Succeed())
+			Expect(obj.Object).To(HaveKeyWithValue("spec", HaveKeyWithValue("template",
+				HaveKeyWithValue("metadata", HaveKeyWithValue("annotations", HaveKeyWithValue(ConfigHashAnnotation("kcb"), "hash"))))))
+
+			_, err = ParseWorkloadKindsConfig([]byte("workloadKinds:\n- kind: Rollout\n"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should order workloads into rollout waves", func() {
+			deployment := func(name, wave string) client.Object {
+				return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"wave": wave}}}
+			}
+			names := func(waves [][]client.Object) [][]string {
+				result := make([][]string, 0)
+				for _, wave := range waves {
+					refs := make([]string, 0)
+					for _, obj := range wave {
+						refs = append(refs, obj.GetName())
+					}
+					result = append(result, refs)
+				}
+				return result
+			}
+			workloads := []client.Object{deployment("a", "10"), deployment("b", "2"), deployment("c", ""), deployment("d", "2")}
+
+			byLabel := rolloutWaves(&kconfigcontrollerv1beta1.RolloutStrategy{WaveLabel: "wave"}, workloads)
+			Expect(names(byLabel)).To(Equal([][]string{{"b", "d"}, {"a"}, {"c"}}))
+
+			byList := rolloutWaves(&kconfigcontrollerv1beta1.RolloutStrategy{Waves: []kconfigcontrollerv1beta1.RolloutWave{
+				{Workloads: []string{"Deployment/c"}},
+				{Workloads: []string{"a", "b"}},
+			}}, workloads)
+			Expect(names(byList)).To(Equal([][]string{{"c"}, {"a", "b"}, {"d"}}))
+
+			Expect(names(rolloutWaves(&kconfigcontrollerv1beta1.RolloutStrategy{}, workloads))).To(Equal([][]string{{"a", "b", "c", "d"}}))
+		})
+
+		It("should only report an updated deployment available once its rollout completes", func() {
+			replicas := int32(2)
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2},
+			}
+			Expect(workloadAvailable(deployment)).To(BeFalse())
+			deployment.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+			Expect(workloadAvailable(deployment)).To(BeTrue())
+			deployment.Generation = 3
+			Expect(workloadAvailable(deployment)).To(BeFalse())
+		})
+
+		It("should roll back to the stable envs when a deployment exceeds its progress deadline", func() {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "rollback-app",
+					Namespace:   "default",
+					Annotations: map[string]string{AllowTemplateUpdatesAnnotation: "true"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "rollback-app"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels:      map[string]string{"app": "rollback-app"},
+							Annotations: map[string]string{ConfigHashAnnotation("rollback-kcb"): "bad"},
+						},
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+			}()
+			deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+				Type:   appsv1.DeploymentProgressing,
+				Status: corev1.ConditionFalse,
+				Reason: "ProgressDeadlineExceeded",
+			}}
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+
+			stableEnvs := []corev1.EnvVar{{Name: "A", Value: "good"}}
+			stableCanary := &kconfigcontrollerv1beta1.Canary{Envs: []corev1.EnvVar{{Name: "A", Value: "candidate"}}, Percent: 10}
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "rollback-kcb", Namespace: "default"},
+				Spec:       kconfigcontrollerv1beta1.KconfigBindingSpec{AutoRollback: true},
+				Status: kconfigcontrollerv1beta1.KconfigBindingStatus{
+					ConfigHash:       "bad",
+					StableConfigHash: "good",
+					StableEnvs:       stableEnvs,
+					StableCanary:     stableCanary,
+				},
+			}
+			controllerReconciler := &KconfigBindingReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			result, err := controllerReconciler.checkRolloutHealth(ctx, kcb, "bad")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Requeue).To(BeTrue())
+			Expect(kcb.Status.FailedConfigHash).To(Equal("bad"))
+			Expect(kcb.Status.Rollback).NotTo(BeNil())
+			Expect(kcb.Status.Rollback.ConfigHash).To(Equal("good"))
+			Expect(kcb.Status.Rollback.Envs).To(Equal(stableEnvs))
+			Expect(kcb.Status.Rollback.Canary).To(Equal(stableCanary))
+		})
+
+		It("should record the canary of a stable rollout", func() {
+			canary := &kconfigcontrollerv1beta1.Canary{Envs: []corev1.EnvVar{{Name: "A", Value: "candidate"}}, Percent: 20}
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "stable-canary-kcb", Namespace: "default"},
+				Spec: kconfigcontrollerv1beta1.KconfigBindingSpec{
+					AutoRollback: true,
+					Envs:         []corev1.EnvVar{{Name: "A", Value: "current"}},
+					Canary:       canary,
+				},
+				Status: kconfigcontrollerv1beta1.KconfigBindingStatus{ConfigHash: "stable-canary"},
+			}
+			controllerReconciler := &KconfigBindingReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := controllerReconciler.checkRolloutHealth(ctx, kcb, "stable-canary")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kcb.Status.StableConfigHash).To(Equal("stable-canary"))
+			Expect(kcb.Status.StableEnvs).To(Equal(kcb.Spec.Envs))
+			Expect(kcb.Status.StableCanary).To(Equal(canary))
+		})
+
+		It("should find injected pods with a stale config", func() {
+			kcb := kconfigcontrollerv1beta1.KconfigBinding{ObjectMeta: metav1.ObjectMeta{Name: "evict-kcb"}}
+			selector := labels.SelectorFromSet(labels.Set{"app": "bare"})
+			pod := func(podLabels, annotations map[string]string) *corev1.Pod {
+				annotations[InjectConfigAnnotation] = "true"
+				return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: podLabels, Annotations: annotations}}
+			}
+			selected := map[string]string{"app": "bare"}
+			hashes := map[string]string{StableVariant: "new"}
+			Expect(podStale(kcb, selector, hashes, pod(selected, map[string]string{InjectedConfigAnnotation("evict-kcb"): "old"}))).To(BeTrue())
+			Expect(podStale(kcb, selector, hashes, pod(selected, map[string]string{InjectedConfigAnnotation("evict-kcb"): "new"}))).To(BeFalse())
+			Expect(podStale(kcb, selector, hashes, pod(selected, map[string]string{ConfigHashAnnotation("evict-kcb"): "old"}))).To(BeFalse())
+			Expect(podStale(kcb, selector, hashes, pod(selected, map[string]string{}))).To(BeFalse())
+			Expect(podStale(kcb, selector, hashes, pod(map[string]string{}, map[string]string{InjectedConfigAnnotation("evict-kcb"): "old"}))).To(BeTrue())
+			Expect(podStale(kcb, selector, hashes, pod(map[string]string{}, map[string]string{}))).To(BeFalse())
+
+			By("comparing canary pods with the canary envs")
+			canaryPod := func(injected string) *corev1.Pod {
+				return pod(selected, map[string]string{
+					InjectedConfigAnnotation("evict-kcb"): injected,
+					CanaryVariantAnnotation("evict-kcb"):  CanaryVariant,
+				})
+			}
+			hashes[CanaryVariant] = "candidate"
+			Expect(podStale(kcb, selector, hashes, canaryPod("candidate"))).To(BeFalse())
+			Expect(podStale(kcb, selector, hashes, canaryPod("new"))).To(BeTrue())
+			delete(hashes, CanaryVariant)
+			Expect(podStale(kcb, selector, hashes, canaryPod("candidate"))).To(BeTrue())
+		})
+
+		It("should only open maintenance windows on schedule", func() {
+			windows := []kconfigcontrollerv1beta1.MaintenanceWindow{{
+				Schedule: "0 22 * * mon-fri",
+				Duration: metav1.Duration{Duration: 4 * time.Hour},
+				TimeZone: "Europe/Berlin",
+			}}
+			// Friday 23:30 in Berlin
+			open, _, err := maintenanceWindowOpen(windows, time.Date(2026, 10, 16, 21, 30, 0, 0, time.UTC))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(open).To(BeTrue())
+			// Saturday 12:00 in Berlin, the next window opens Monday
+			open, next, err := maintenanceWindowOpen(windows, time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(open).To(BeFalse())
+			Expect(next.UTC()).To(Equal(time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)))
+
+			_, _, err = maintenanceWindowOpen([]kconfigcontrollerv1beta1.MaintenanceWindow{{Schedule: "0 25 * * *"}}, time.Now())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should parse ranges, steps and day fields of maintenance window schedules", func() {
+			nextStart := func(schedule string, after time.Time) time.Time {
+				parsed, err := parseCronSchedule(schedule)
+				Expect(err).NotTo(HaveOccurred())
+				return parsed.Next(after)
+			}
+			// Saturday 2026-10-17 12:00
+			now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+			// a range of hours
+			Expect(nextStart("0 9-17 * * *", now)).To(Equal(time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)))
+			Expect(nextStart("0 9-11 * * *", now)).To(Equal(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)))
+			// steps over the whole field and over a range
+			Expect(nextStart("*/20 * * * *", now.Add(time.Minute))).To(Equal(time.Date(2026, 10, 17, 12, 20, 0, 0, time.UTC)))
+			Expect(nextStart("0 1-23/6 * * *", now)).To(Equal(time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)))
+			// a day-of-week range by name skips the weekend
+			Expect(nextStart("0 2 * * mon-fri", now)).To(Equal(time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)))
+			// a restricted day-of-month alone
+			Expect(nextStart("0 2 1 * *", now)).To(Equal(time.Date(2026, 11, 1, 2, 0, 0, 0, time.UTC)))
+			// a restricted day-of-month and day-of-week match either
+			Expect(nextStart("0 2 1 * mon", now)).To(Equal(time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)))
+			Expect(nextStart("0 2 20 * mon", time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC))).To(Equal(time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC)))
+			// an unrestricted day-of-month leaves the day to the day-of-week
+			Expect(nextStart("0 2 * * sun", now)).To(Equal(time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)))
+			Expect(nextStart("@daily", now)).To(Equal(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+
+			for _, schedule := range []string{"0 25 * * *", "0 2 * *", "@every 1h", "CRON_TZ=UTC 0 2 * * *"} {
+				_, err := parseCronSchedule(schedule)
+				Expect(err).To(HaveOccurred(), schedule)
+			}
+		})
+
+		It("should inject the config last rolled out outside of the maintenance windows", func() {
+			rolledOut := []corev1.EnvVar{{Name: "A", Value: "old"}}
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+				Spec: kconfigcontrollerv1beta1.KconfigBindingSpec{
+					Envs: []corev1.EnvVar{{Name: "A", Value: "new"}},
+					MaintenanceWindows: []kconfigcontrollerv1beta1.MaintenanceWindow{{
+						Schedule: "0 2 * * *",
+						Duration: metav1.Duration{Duration: time.Hour},
+					}},
+				},
+				Status: kconfigcontrollerv1beta1.KconfigBindingStatus{
+					RolledOut: &kconfigcontrollerv1beta1.RolledOutConfig{Envs: rolledOut},
+				},
+			}
+			envs, _ := InjectedEnvs(kcb, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+			Expect(envs).To(Equal(rolledOut))
+			envs, _ = InjectedEnvs(kcb, time.Date(2026, 10, 17, 2, 30, 0, 0, time.UTC))
+			Expect(envs).To(Equal(kcb.Spec.Envs))
+
+			kcb.Annotations = map[string]string{RolloutWindowOverrideAnnotation: "true"}
+			envs, _ = InjectedEnvs(kcb, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+			Expect(envs).To(Equal(kcb.Spec.Envs))
+		})
+
+		It("should hold back a rollout outside of the maintenance windows", func() {
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "window-kcb", Namespace: "default"},
+				Spec: kconfigcontrollerv1beta1.KconfigBindingSpec{
+					MaintenanceWindows: []kconfigcontrollerv1beta1.MaintenanceWindow{{
+						Schedule: "0 2 * * *",
+						Duration: metav1.Duration{Duration: time.Hour},
+					}},
+				},
+			}
+			controllerReconciler := &KconfigBindingReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+			deferred, result, err := controllerReconciler.deferRollout(ctx, kcb, "hash", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deferred).To(BeTrue())
+			Expect(result.RequeueAfter).To(Equal(14 * time.Hour))
+			Expect(meta.IsStatusConditionTrue(kcb.Status.Conditions, RolloutPendingCondition)).To(BeTrue())
+
+			kcb.Annotations = map[string]string{RolloutWindowOverrideAnnotation: "true"}
+			deferred, _, err = controllerReconciler.deferRollout(ctx, kcb, "hash", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deferred).To(BeFalse())
+			Expect(meta.IsStatusConditionFalse(kcb.Status.Conditions, RolloutPendingCondition)).To(BeTrue())
+		})
+
+		It("should keep a deleted binding until its workloads are rolled off", func() {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "rolled-off-app",
+					Namespace:   "default",
+					Annotations: map[string]string{AllowTemplateUpdatesAnnotation: "true"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "rolled-off-app"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels:      map[string]string{"app": "rolled-off-app"},
+							Annotations: map[string]string{ConfigHashAnnotation("rolled-off-kcb"): "old"},
+						},
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+			}()
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "rolled-off-kcb",
+					Namespace:  "default",
+					Finalizers: []string{KconfigBindingRolloutFinalizer},
+				},
+				Spec: kconfigcontrollerv1beta1.KconfigBindingSpec{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "rolled-off-app"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, kcb)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, kcb)).To(Succeed())
+
+			controllerReconciler := &KconfigBindingReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			nn := client.ObjectKeyFromObject(kcb)
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: nn})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(RolloutRequeueInterval))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Annotations).NotTo(HaveKey(ConfigHashAnnotation("rolled-off-kcb")))
+
+			By("waiting for the rolled off deployment to become available")
+			Expect(k8sClient.Get(ctx, nn, kcb)).To(Succeed())
+			Expect(kcb.Finalizers).To(ContainElement(KconfigBindingRolloutFinalizer))
+			Expect(kcb.Status.Rollout.Progressing).To(Equal([]string{"Deployment/rolled-off-app"}))
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: nn})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nn, kcb)).To(Succeed())
+
+			deployment.Status.ObservedGeneration = deployment.Generation
+			deployment.Status.Replicas = 1
+			deployment.Status.UpdatedReplicas = 1
+			deployment.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: nn})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, nn, kcb))).To(BeTrue())
+		})
+
+		It("should change the config hash when a canary changes", func() {
+			controllerReconciler := &KconfigBindingReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			kcb := &kconfigcontrollerv1beta1.KconfigBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default", UID: "uid-1"}}
+			envs := []corev1.EnvVar{{Name: "A", Value: "stable"}}
+			hash, err := controllerReconciler.configHash(ctx, kcb, envs)
+			Expect(err).NotTo(HaveOccurred())
+			other := &kconfigcontrollerv1beta1.KconfigBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default", UID: "uid-2"}}
+			otherHash, err := controllerReconciler.configHash(ctx, other, envs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherHash).NotTo(Equal(hash))
+			canary := &kconfigcontrollerv1beta1.Canary{Envs: []corev1.EnvVar{{Name: "A", Value: "candidate"}}, Percent: 10}
+			canaryHash, err := controllerReconciler.canaryConfigHash(ctx, kcb, hash, canary)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(canaryHash).To(HaveLen(ConfigHashLength))
+			Expect(canaryHash).NotTo(Equal(hash))
+			canary.Percent = 50
+			widenedHash, err := controllerReconciler.canaryConfigHash(ctx, kcb, hash, canary)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(widenedHash).NotTo(Equal(canaryHash))
+		})
+
+		It("should leave workloads pinned to a config revision out of the rollout", func() {
+			value := "old"
+			rev := &kconfigcontrollerv1beta1.KconfigRevision{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      historyName("pin-kcb", 1),
+					Namespace: "default",
+					Labels:    map[string]string{KconfigNameLabel: "pin-kcb"},
+				},
+				Spec: kconfigcontrollerv1beta1.KconfigRevisionSpec{
+					KconfigName: "pin-kcb",
+					Revision:    1,
+					Hash:        "0123456789abcdef",
+					EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{
+						{Type: ConfigMapEnvConfigType, Key: "A", Value: &value},
+						{Type: SecretEnvConfigType, Key: "B", SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: historyName("pin-kcb", 1)},
+							Key:                  "B",
+						}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, rev)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, rev)).To(Succeed())
+			}()
+			Expect(RevisionEnvs(rev)).To(Equal([]corev1.EnvVar{
+				{Name: "A", Value: "old"},
+				{Name: "B", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: rev.Spec.EnvConfigs[1].SecretKeyRef}},
+			}))
+
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pinned-app",
+					Namespace:   "default",
+					Annotations: map[string]string{AllowTemplateUpdatesAnnotation: "true"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "pinned-app"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels:      map[string]string{"app": "pinned-app"},
+							Annotations: map[string]string{PinRevisionAnnotation: "0123456789abcdef"},
+						},
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+			}()
+
+			controllerReconciler := &KconfigBindingReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			kcb := kconfigcontrollerv1beta1.KconfigBinding{ObjectMeta: metav1.ObjectMeta{Name: "pin-kcb", Namespace: "default"}}
+			Expect(controllerReconciler.updateRolledWorkloads(ctx, kcb, "new")).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Annotations).NotTo(HaveKey(ConfigHashAnnotation("pin-kcb")))
+
+			By("rolling out to a binding of another kconfig")
+			other := kconfigcontrollerv1beta1.KconfigBinding{ObjectMeta: metav1.ObjectMeta{Name: "other-kcb", Namespace: "default"}}
+			Expect(controllerReconciler.updateRolledWorkloads(ctx, other, "new")).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue(ConfigHashAnnotation("other-kcb"), "new"))
 		})
 	})
 })
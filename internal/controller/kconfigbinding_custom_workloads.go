@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// WorkloadKindsConfig is the manager configuration of additional workload kinds embedding a pod template
+type WorkloadKindsConfig struct {
+	WorkloadKinds []WorkloadKind `json:"workloadKinds"`
+}
+
+// WorkloadKind identifies a workload kind and the path of its pod template, e.g. for an Argo Rollout
+// group argoproj.io, version v1alpha1, kind Rollout and podTemplatePath .spec.template
+type WorkloadKind struct {
+	Group           string `json:"group"`
+	Version         string `json:"version"`
+	Kind            string `json:"kind"`
+	PodTemplatePath string `json:"podTemplatePath"`
+}
+
+// ParseWorkloadKindsConfig reads a YAML or JSON WorkloadKindsConfig
+func ParseWorkloadKindsConfig(data []byte) ([]WorkloadKind, error) {
+	var config WorkloadKindsConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing workload kinds config: %s", err.Error())
+	}
+	for _, kind := range config.WorkloadKinds {
+		if kind.Version == "" || kind.Kind == "" || len(kind.path()) == 0 {
+			return nil, fmt.Errorf("workload kind %s requires version, kind and podTemplatePath", kind.GroupVersionKind())
+		}
+	}
+	return config.WorkloadKinds, nil
+}
+
+func (w WorkloadKind) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: w.Group, Version: w.Version, Kind: w.Kind}
+}
+
+// path splits the pod template path into its fields
+func (w WorkloadKind) path() []string {
+	fields := make([]string, 0)
+	for _, field := range strings.Split(strings.TrimPrefix(w.PodTemplatePath, "."), ".") {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func (w WorkloadKind) newObject() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(w.GroupVersionKind())
+	return obj
+}
+
+// podTemplate reads the pod template of an object of this kind, it returns nil if there is none
+func (w WorkloadKind) podTemplate(obj *unstructured.Unstructured) (*corev1.PodTemplateSpec, error) {
+	raw, found, err := unstructured.NestedMap(obj.Object, w.path()...)
+	if err != nil || !found {
+		return nil, err
+	}
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// setPodTemplateAnnotations writes back the annotations of the pod template of an object of this kind
+func (w WorkloadKind) setPodTemplateAnnotations(obj *unstructured.Unstructured, annotations map[string]string) error {
+	path := append(w.path(), "metadata", "annotations")
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, path...)
+		return nil
+	}
+	return unstructured.SetNestedStringMap(obj.Object, annotations, path...)
+}
+
+// workloadKindOf returns the configured workload kind of an unstructured object
+func (r *KconfigBindingReconciler) workloadKindOf(obj client.Object) (WorkloadKind, bool) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	for _, kind := range r.WorkloadKinds {
+		if kind.GroupVersionKind() == gvk {
+			return kind, true
+		}
+	}
+	return WorkloadKind{}, false
+}
+
+// templateOf returns the pod template of a built-in or configured workload kind, or nil
+func (r *KconfigBindingReconciler) templateOf(obj client.Object) *corev1.PodTemplateSpec {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return podTemplateOf(obj)
+	}
+	kind, ok := r.workloadKindOf(u)
+	if !ok {
+		return nil
+	}
+	template, err := kind.podTemplate(u)
+	if err != nil {
+		r.Log.Error(err, "error reading pod template", "workload", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	return template
+}
+
+// listCustomWorkloads lists the objects of the configured workload kinds
+func (r *KconfigBindingReconciler) listCustomWorkloads(ctx context.Context, namespace string) ([]client.Object, error) {
+	workloads := make([]client.Object, 0)
+	for _, kind := range r.WorkloadKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(kind.GroupVersionKind().GroupVersion().WithKind(kind.Kind + "List"))
+		if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("error getting %s list: %s", kind.Kind, err.Error())
+		}
+		for i := range list.Items {
+			workloads = append(workloads, &list.Items[i])
+		}
+	}
+	return workloads, nil
+}
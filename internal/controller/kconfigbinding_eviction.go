@@ -0,0 +1,128 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+
+// evictStalePods evicts the pods of a binding with evictStalePods that were injected with other envs
+// than the binding injects now. Evictions refused by a PodDisruptionBudget are retried later.
+func (r *KconfigBindingReconciler) evictStalePods(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding) (ctrl.Result, error) {
+	if !kcb.Spec.EvictStalePods {
+		return ctrl.Result{}, nil
+	}
+	hashes, err := r.injectedConfigHashes(ctx, &kcb)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(kcb.Namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("error getting podList: %s", err.Error())
+	}
+	blocked := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !podStale(kcb, selector, hashes, pod) {
+			continue
+		}
+		if pinned, err := r.pinned(ctx, kcb, pod.Annotations); err != nil {
+			return ctrl.Result{}, err
+		} else if pinned {
+			continue
+		}
+		eviction := &policyv1.Eviction{ObjectMeta: v12.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name}}
+		if err := r.SubResource("eviction").Create(ctx, pod, eviction); err != nil {
+			switch {
+			case errors.IsNotFound(err):
+			case errors.IsTooManyRequests(err):
+				// a disruption budget does not allow the eviction right now
+				blocked = true
+			default:
+				return ctrl.Result{}, fmt.Errorf("error evicting pod %s: %s", pod.Name, err.Error())
+			}
+		}
+	}
+	if blocked {
+		return ctrl.Result{RequeueAfter: RolloutRequeueInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// injectedConfigHashes returns the config hashes of the envs the binding injects by canary variant
+func (r *KconfigBindingReconciler) injectedConfigHashes(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding) (map[string]string, error) {
+	envs, canary := InjectedEnvs(kcb, time.Now())
+	hash, err := r.configHash(ctx, kcb, envs)
+	if err != nil {
+		return nil, fmt.Errorf("error computing config hash: %s", err.Error())
+	}
+	hashes := map[string]string{StableVariant: hash}
+	if canary != nil {
+		if hashes[CanaryVariant], err = r.configHash(ctx, kcb, canary.Envs); err != nil {
+			return nil, fmt.Errorf("error computing canary config hash: %s", err.Error())
+		}
+	}
+	return hashes, nil
+}
+
+// podStale reports whether a running injected pod carries another config of the binding than the
+// binding injects into its canary variant now. Pods keep their variant, and pods injected before
+// the config of the binding was recorded are left alone. Pods from an opted-in pod template carry
+// its config hash annotation and are rolled through the template instead.
+func podStale(kcb kconfigcontrollerv1beta1.KconfigBinding, selector labels.Selector, hashes map[string]string, pod *corev1.Pod) bool {
+	if strings.ToLower(pod.Annotations[InjectConfigAnnotation]) != "true" || !pod.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, templated := pod.Annotations[ConfigHashAnnotation(kcb.Name)]; templated {
+		return false
+	}
+	injected, ok := pod.Annotations[InjectedConfigAnnotation(kcb.Name)]
+	if !ok {
+		return false
+	}
+	if !selector.Matches(labels.Set(pod.Labels)) {
+		return true
+	}
+	variant := pod.Annotations[CanaryVariantAnnotation(kcb.Name)]
+	if variant == "" {
+		variant = StableVariant
+	}
+	return injected != hashes[variant]
+}
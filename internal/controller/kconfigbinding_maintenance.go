@@ -0,0 +1,104 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// deferRollout holds back the rollout of a binding outside of its maintenance windows, refreshing only
+// CronJobs, and reports it with the RolloutPending condition. It returns whether the rollout is
+// deferred and when to look again.
+func (r *KconfigBindingReconciler) deferRollout(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding, hash string, now time.Time) (bool, ctrl.Result, error) {
+	if len(kcb.Spec.MaintenanceWindows) == 0 {
+		meta.RemoveStatusCondition(&kcb.Status.Conditions, RolloutPendingCondition)
+		return false, ctrl.Result{}, nil
+	}
+	if kcb.Annotations[RolloutWindowOverrideAnnotation] == "true" {
+		setRolloutPending(kcb, v12.ConditionFalse, WindowOverrideReason, "maintenance windows are overridden")
+		return false, ctrl.Result{}, nil
+	}
+	if kcb.Status.Rollback != nil {
+		setRolloutPending(kcb, v12.ConditionFalse, RollingBackReason, "rollbacks are not held back by maintenance windows")
+		return false, ctrl.Result{}, nil
+	}
+	open, next, err := maintenanceWindowOpen(kcb.Spec.MaintenanceWindows, now)
+	if err != nil {
+		// without valid windows nothing is rolled out until the binding is fixed
+		setRolloutPending(kcb, v12.ConditionTrue, InvalidMaintenanceWindowReason, err.Error())
+		return true, ctrl.Result{}, r.updateCronJobs(ctx, *kcb, hash)
+	}
+	if open {
+		setRolloutPending(kcb, v12.ConditionFalse, InMaintenanceWindowReason, "a maintenance window is open")
+		return false, ctrl.Result{}, nil
+	}
+
+	if err := r.updateCronJobs(ctx, *kcb, hash); err != nil {
+		return true, ctrl.Result{}, fmt.Errorf("error updating cronjobs: %s", err.Error())
+	}
+	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+	if err != nil {
+		return true, ctrl.Result{}, fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	}
+	_, stale, err := r.rolloutTargets(ctx, *kcb, selector, hash)
+	if err != nil {
+		return true, ctrl.Result{}, err
+	}
+	pending := kcb.Status.ConfigHash != hash
+	for _, isStale := range stale {
+		pending = pending || isStale
+	}
+	if !pending {
+		setRolloutPending(kcb, v12.ConditionFalse, UpToDateReason, "no changes are pending")
+		return true, ctrl.Result{}, nil
+	}
+	if next.IsZero() {
+		setRolloutPending(kcb, v12.ConditionTrue, OutsideMaintenanceWindowReason, "no maintenance window opens in the next five years")
+		return true, ctrl.Result{}, nil
+	}
+	setRolloutPending(kcb, v12.ConditionTrue, OutsideMaintenanceWindowReason,
+		fmt.Sprintf("rollout of config %s is pending until the next maintenance window at %s", hash, next.Format(time.RFC3339)))
+	return true, ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// rolloutHeldBack reports whether the rollout of a binding is held back at now, as it is outside of its
+// maintenance windows or they are invalid
+func rolloutHeldBack(kcb *kconfigcontrollerv1beta1.KconfigBinding, now time.Time) bool {
+	if len(kcb.Spec.MaintenanceWindows) == 0 || kcb.Annotations[RolloutWindowOverrideAnnotation] == "true" || kcb.Status.Rollback != nil {
+		return false
+	}
+	open, _, err := maintenanceWindowOpen(kcb.Spec.MaintenanceWindows, now)
+	return err != nil || !open
+}
+
+func setRolloutPending(kcb *kconfigcontrollerv1beta1.KconfigBinding, status v12.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&kcb.Status.Conditions, v12.Condition{
+		Type:               RolloutPendingCondition,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: kcb.Generation,
+	})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// PinnedRevisions returns the KconfigRevision names or hashes a pod or pod template is pinned to
+func PinnedRevisions(annotations map[string]string) []string {
+	pins := make([]string, 0)
+	for _, pin := range strings.Split(annotations[PinRevisionAnnotation], ",") {
+		if pin = strings.TrimSpace(pin); pin != "" {
+			pins = append(pins, pin)
+		}
+	}
+	return pins
+}
+
+// PinnedKconfigName returns the name of the Kconfig a pin names by revision name, or false for pins
+// by hash and other names
+func PinnedKconfigName(pin string) (string, bool) {
+	i := strings.LastIndex(pin, "-rev-")
+	if i <= 0 {
+		return "", false
+	}
+	if _, err := strconv.ParseInt(pin[i+len("-rev-"):], 10, 64); err != nil {
+		return "", false
+	}
+	return pin[:i], true
+}
+
+// PinnedRevision returns the revision of the Kconfig of a binding among the pinned revisions, or nil.
+// Bindings generated from a Kconfig carry its name.
+func PinnedRevision(revisions []kconfigcontrollerv1beta1.KconfigRevision, pins []string, kcbName string) *kconfigcontrollerv1beta1.KconfigRevision {
+	for i, rev := range revisions {
+		if rev.Spec.KconfigName != kcbName {
+			continue
+		}
+		for _, pin := range pins {
+			if pin == rev.Name || pin == rev.Spec.Hash {
+				return &revisions[i]
+			}
+		}
+	}
+	return nil
+}
+
+// RevisionEnvs returns the envs of the configuration recorded in a KconfigRevision. Generated
+// ConfigMap values are inlined and secret values are referenced from the Secret of the revision.
+func RevisionEnvs(rev *kconfigcontrollerv1beta1.KconfigRevision) []v1.EnvVar {
+	envs := make([]v1.EnvVar, 0, len(rev.Spec.EnvConfigs))
+	for _, ec := range rev.Spec.EnvConfigs {
+		env := v1.EnvVar{Name: ec.Key}
+		switch {
+		case ec.SecretKeyRef != nil:
+			env.ValueFrom = &v1.EnvVarSource{SecretKeyRef: ec.SecretKeyRef.DeepCopy()}
+		case ec.ConfigMapKeyRef != nil:
+			env.ValueFrom = &v1.EnvVarSource{ConfigMapKeyRef: ec.ConfigMapKeyRef.DeepCopy()}
+		case ec.FieldRef != nil:
+			env.ValueFrom = &v1.EnvVarSource{FieldRef: ec.FieldRef.DeepCopy()}
+		case ec.ResourceFieldRef != nil:
+			env.ValueFrom = &v1.EnvVarSource{ResourceFieldRef: ec.ResourceFieldRef.DeepCopy()}
+		case ec.Value != nil:
+			env.Value = *ec.Value
+		default:
+			continue
+		}
+		envs = append(envs, env)
+	}
+	return envs
+}
+
+// pinned reports whether a pod template is pinned to a revision of the Kconfig of the binding, in
+// which case its config is frozen and it is left out of rollouts
+func (r *KconfigBindingReconciler) pinned(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, annotations map[string]string) (bool, error) {
+	pins := PinnedRevisions(annotations)
+	if len(pins) == 0 {
+		return false, nil
+	}
+	var revisions kconfigcontrollerv1beta1.KconfigRevisionList
+	if err := r.List(ctx, &revisions, client.InNamespace(kcb.Namespace), client.MatchingLabels{KconfigNameLabel: kcb.Name}); err != nil {
+		return false, fmt.Errorf("error getting kconfigRevisionList: %s", err.Error())
+	}
+	return PinnedRevision(revisions.Items, pins, kcb.Name) != nil, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// checkRolloutHealth watches over the rollout of the config hash of a binding with auto rollback.
+// A failed rollout is rolled back to the stable envs and canary, a rollout whose workloads all became
+// available becomes the stable one.
+func (r *KconfigBindingReconciler) checkRolloutHealth(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding, hash string) (ctrl.Result, error) {
+	if !kcb.Spec.AutoRollback || kcb.Status.StableConfigHash == hash {
+		return ctrl.Result{}, nil
+	}
+	failure, err := r.rolloutFailure(ctx, *kcb, hash)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if failure != "" {
+		if kcb.Status.FailedConfigHash != hash {
+			kcb.Status.FailedConfigHash = hash
+			r.Recorder.Eventf(kcb, WarningEventType, RolloutFailedEvent, "rollout of config %s failed: %s", hash, failure)
+		}
+		// there is nothing to roll back to, or the rolled back config failed as well
+		if kcb.Status.Rollback != nil || kcb.Status.StableConfigHash == "" {
+			return ctrl.Result{}, nil
+		}
+		kcb.Status.Rollback = &kconfigcontrollerv1beta1.RollbackStatus{
+			FailedConfigHash: hash,
+			ConfigHash:       kcb.Status.StableConfigHash,
+			Envs:             kcb.Status.StableEnvs,
+			Canary:           kcb.Status.StableCanary,
+			Message:          failure,
+			Time:             v12.Now(),
+		}
+		r.Recorder.Eventf(kcb, WarningEventType, RolledBackEvent, "rolled back config %s to %s", hash, kcb.Status.StableConfigHash)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if kcb.Status.ConfigHash != hash {
+		// the rollout strategy requeues until the config hash is rolled out
+		return ctrl.Result{}, nil
+	}
+	available, err := r.rolloutAvailable(ctx, *kcb, hash)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !available {
+		return ctrl.Result{RequeueAfter: RolloutRequeueInterval}, nil
+	}
+	if kcb.Status.Rollback == nil {
+		kcb.Status.StableConfigHash = hash
+		kcb.Status.StableEnvs = kcb.Spec.Envs
+		kcb.Status.StableCanary = kcb.Spec.Canary
+	}
+	return ctrl.Result{}, nil
+}
+
+// rolloutFailure describes why the rollout of the config hash failed, or returns an empty string
+func (r *KconfigBindingReconciler) rolloutFailure(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) (string, error) {
+	annotation := ConfigHashAnnotation(kcb.Name)
+	workloads, err := r.listRolledWorkloads(ctx, kcb.Namespace)
+	if err != nil {
+		return "", err
+	}
+	for _, obj := range workloads {
+		deployment, ok := obj.(*v1.Deployment)
+		if !ok || deployment.Spec.Template.Annotations[annotation] != hash {
+			continue
+		}
+		for _, condition := range deployment.Status.Conditions {
+			if condition.Type == v1.DeploymentProgressing && condition.Status == corev1.ConditionFalse && condition.Reason == "ProgressDeadlineExceeded" {
+				return fmt.Sprintf("%s exceeded its progress deadline", workloadRef(obj)), nil
+			}
+		}
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(kcb.Namespace)); err != nil {
+		return "", fmt.Errorf("error getting podList: %s", err.Error())
+	}
+	for _, pod := range pods.Items {
+		if pod.Annotations[annotation] != hash {
+			continue
+		}
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+				return fmt.Sprintf("container %s of pod %s is crash-looping", status.Name, pod.Name), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// rolloutAvailable reports whether all workloads carrying the config hash are available
+func (r *KconfigBindingReconciler) rolloutAvailable(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) (bool, error) {
+	workloads, err := r.listRolledWorkloads(ctx, kcb.Namespace)
+	if err != nil {
+		return false, err
+	}
+	for _, obj := range workloads {
+		template := r.templateOf(obj)
+		if template == nil || template.Annotations[ConfigHashAnnotation(kcb.Name)] != hash {
+			continue
+		}
+		if !workloadAvailable(obj) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// earliestResult combines the results of the reconcile steps
+func earliestResult(a, b ctrl.Result) ctrl.Result {
+	if a.Requeue || b.Requeue {
+		return ctrl.Result{Requeue: true}
+	}
+	if a.RequeueAfter == 0 || (b.RequeueAfter != 0 && b.RequeueAfter < a.RequeueAfter) {
+		return b
+	}
+	return a
+}
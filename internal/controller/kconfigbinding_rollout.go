@@ -0,0 +1,250 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// rolloutWorkloads advances the rollout of the config hash to the rolled workloads according to the
+// rollout strategy of the binding, all at once without one, and records its progress in status. It
+// returns whether the rollout is complete.
+func (r *KconfigBindingReconciler) rolloutWorkloads(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding, hash string) (bool, error) {
+	strategy := kcb.Spec.RolloutStrategy
+	if strategy == nil {
+		strategy = &kconfigcontrollerv1beta1.RolloutStrategy{}
+	}
+	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+	if err != nil {
+		return false, fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	}
+	targets, stale, err := r.rolloutTargets(ctx, *kcb, selector, hash)
+	if err != nil {
+		return false, err
+	}
+
+	waves := rolloutWaves(strategy, targets)
+	status := &kconfigcontrollerv1beta1.RolloutStatus{
+		ConfigHash:     hash,
+		Phase:          RolloutCompletePhase,
+		Waves:          int32(len(waves)),
+		TotalWorkloads: int32(len(targets)),
+	}
+	for _, obj := range targets {
+		if !stale[obj] {
+			status.UpdatedWorkloads++
+		}
+	}
+	kcb.Status.Rollout = status
+	for i, wave := range waves {
+		status.Wave = int32(i + 1)
+		progressing := make([]string, 0)
+		pending := make([]client.Object, 0)
+		for _, obj := range wave {
+			if stale[obj] {
+				pending = append(pending, obj)
+			} else if !workloadAvailable(obj) {
+				progressing = append(progressing, workloadRef(obj))
+			}
+		}
+		slots := len(pending)
+		if strategy.MaxConcurrent > 0 {
+			slots = int(strategy.MaxConcurrent) - len(progressing)
+		}
+		for j := 0; j < slots && j < len(pending); j++ {
+			if err := r.syncWorkload(ctx, *kcb, selector, hash, pending[j]); err != nil {
+				return false, fmt.Errorf("error updating %s: %s", workloadRef(pending[j]), err.Error())
+			}
+			status.UpdatedWorkloads++
+			progressing = append(progressing, workloadRef(pending[j]))
+		}
+		if len(progressing) > 0 || len(pending) > 0 {
+			status.Phase = RolloutProgressingPhase
+			status.Progressing = progressing
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// rolloutTargets returns the opted-in rolled workloads taking part in the rollout of the config hash,
+// i.e. those selected by the binding, those still carrying its hash and those still progressing
+// after their hash was removed, unless pinned, and which of them are stale
+func (r *KconfigBindingReconciler) rolloutTargets(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, selector labels.Selector, hash string) ([]client.Object, map[client.Object]bool, error) {
+	workloads, err := r.listRolledWorkloads(ctx, kcb.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	progressing := make(map[string]bool)
+	if kcb.Status.Rollout != nil && kcb.Status.Rollout.ConfigHash == hash {
+		for _, ref := range kcb.Status.Rollout.Progressing {
+			progressing[ref] = true
+		}
+	}
+	targets := make([]client.Object, 0)
+	stale := make(map[client.Object]bool)
+	for _, obj := range workloads {
+		if obj.GetAnnotations()[AllowTemplateUpdatesAnnotation] != "true" {
+			continue
+		}
+		template := r.templateOf(obj)
+		if template == nil {
+			continue
+		}
+		if pinned, err := r.pinned(ctx, kcb, template.Annotations); err != nil {
+			return nil, nil, err
+		} else if pinned {
+			continue
+		}
+		wanted := wantedConfigHash(selector, template, hash)
+		if _, annotated := template.Annotations[ConfigHashAnnotation(kcb.Name)]; wanted == "" && !annotated && !progressing[workloadRef(obj)] {
+			continue
+		}
+		targets = append(targets, obj)
+		stale[obj] = syncConfigHashAnnotation(template.DeepCopy(), kcb.Name, wanted)
+	}
+	return targets, stale, nil
+}
+
+// rolloutWaves orders the workloads into the waves of the strategy, sorting each wave by kind/name
+func rolloutWaves(strategy *kconfigcontrollerv1beta1.RolloutStrategy, workloads []client.Object) [][]client.Object {
+	waveOf := func(obj client.Object) string { return "" }
+	order := []string{""}
+	switch {
+	case len(strategy.Waves) > 0:
+		index := make(map[string]string)
+		order = make([]string, 0, len(strategy.Waves)+1)
+		for i, wave := range strategy.Waves {
+			key := strconv.Itoa(i)
+			order = append(order, key)
+			for _, name := range wave.Workloads {
+				if _, ok := index[name]; !ok {
+					index[name] = key
+				}
+			}
+		}
+		order = append(order, "")
+		waveOf = func(obj client.Object) string {
+			if key, ok := index[workloadRef(obj)]; ok {
+				return key
+			}
+			return index[obj.GetName()]
+		}
+	case strategy.WaveLabel != "":
+		waveOf = func(obj client.Object) string { return obj.GetLabels()[strategy.WaveLabel] }
+		values := make(map[string]bool)
+		for _, obj := range workloads {
+			if value := waveOf(obj); value != "" {
+				values[value] = true
+			}
+		}
+		order = append(sortWaveValues(sortedKeys(values)), "")
+	}
+
+	grouped := make(map[string][]client.Object)
+	for _, obj := range workloads {
+		grouped[waveOf(obj)] = append(grouped[waveOf(obj)], obj)
+	}
+	waves := make([][]client.Object, 0, len(order))
+	for _, key := range order {
+		wave := grouped[key]
+		if len(wave) == 0 {
+			continue
+		}
+		sort.Slice(wave, func(i, j int) bool { return workloadRef(wave[i]) < workloadRef(wave[j]) })
+		waves = append(waves, wave)
+	}
+	return waves
+}
+
+// sortWaveValues orders sorted wave label values numerically when they are all integers
+func sortWaveValues(values []string) []string {
+	numbers := make(map[string]int, len(values))
+	for _, value := range values {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return values
+		}
+		numbers[value] = number
+	}
+	sort.SliceStable(values, func(i, j int) bool { return numbers[values[i]] < numbers[values[j]] })
+	return values
+}
+
+// workloadAvailable reports whether the rollout of the current template of a workload is complete
+// and all of its replicas are available
+func workloadAvailable(obj client.Object) bool {
+	switch workload := obj.(type) {
+	case *v1.Deployment:
+		replicas := int32(1)
+		if workload.Spec.Replicas != nil {
+			replicas = *workload.Spec.Replicas
+		}
+		return workload.Status.ObservedGeneration >= workload.Generation &&
+			workload.Status.UpdatedReplicas == replicas &&
+			workload.Status.Replicas == replicas &&
+			workload.Status.AvailableReplicas == replicas
+	case *v1.StatefulSet:
+		if workload.Status.ObservedGeneration < workload.Generation {
+			return false
+		}
+		// pods of an OnDelete statefulSet are only replaced when deleted by someone else
+		if workload.Spec.UpdateStrategy.Type == v1.OnDeleteStatefulSetStrategyType {
+			return true
+		}
+		replicas := int32(1)
+		if workload.Spec.Replicas != nil {
+			replicas = *workload.Spec.Replicas
+		}
+		return workload.Status.UpdateRevision == workload.Status.CurrentRevision &&
+			workload.Status.UpdatedReplicas == replicas &&
+			workload.Status.AvailableReplicas == replicas
+	case *v1.DaemonSet:
+		if workload.Status.ObservedGeneration < workload.Generation {
+			return false
+		}
+		if workload.Spec.UpdateStrategy.Type == v1.OnDeleteDaemonSetStrategyType {
+			return true
+		}
+		return workload.Status.UpdatedNumberScheduled == workload.Status.DesiredNumberScheduled &&
+			workload.Status.NumberAvailable == workload.Status.DesiredNumberScheduled
+	case *unstructured.Unstructured:
+		// configured workload kinds are judged by the conventional observedGeneration and Available condition
+		if observed, found, _ := unstructured.NestedInt64(workload.Object, "status", "observedGeneration"); found && observed < workload.GetGeneration() {
+			return false
+		}
+		conditions, _, _ := unstructured.NestedSlice(workload.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == "Available" {
+				return condition["status"] == "True"
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// processKconfigBinding brings the workloads up to date with the config hash, all at once or along
+// the rollout strategy of the binding, within its maintenance windows. The config hash and the config
+// it was computed from are recorded in status once rolled out.
+func (r *KconfigBindingReconciler) processKconfigBinding(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding, hash string) (ctrl.Result, error) {
+	now := time.Now()
+	if deferred, result, err := r.deferRollout(ctx, kcb, hash, now); err != nil || deferred {
+		return result, err
+	}
+	var jobsPending bool
+	if kcb.Spec.RolloutStrategy == nil {
+		pending, err := r.updateWorkloads(ctx, *kcb, hash)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		jobsPending = pending
+		kcb.Status.Rollout = nil
+	} else {
+		pending, err := r.updateJobWorkloads(ctx, *kcb, hash)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		jobsPending = pending
+		complete, err := r.rolloutWorkloads(ctx, kcb, hash)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !complete {
+			// workload status changes are not watched, progress is polled
+			return ctrl.Result{RequeueAfter: RolloutRequeueInterval}, nil
+		}
+	}
+	kcb.Status.ConfigHash = hash
+	envs, canary := InjectedEnvs(kcb, now)
+	kcb.Status.RolledOut = &kconfigcontrollerv1beta1.RolledOutConfig{Envs: envs, Canary: canary}
+	result, err := r.evictStalePods(ctx, *kcb)
+	if err != nil || !jobsPending {
+		return result, err
+	}
+	return earliestResult(result, ctrl.Result{RequeueAfter: RolloutRequeueInterval}), nil
+}
+
+// removeBindingAnnotations rolls the workloads that were rolled for a binding which is going away by
+// removing its annotation from their pod templates, along the rollout strategy and within the
+// maintenance windows of the binding. The pod injector skips bindings being deleted, so the new pods
+// come up without the binding's envs. It returns whether the workloads are rolled off and available.
+func (r *KconfigBindingReconciler) removeBindingAnnotations(ctx context.Context, kcb *kconfigcontrollerv1beta1.KconfigBinding) (bool, ctrl.Result, error) {
+	if deferred, result, err := r.deferRollout(ctx, kcb, "", time.Now()); err != nil || deferred {
+		return false, result, err
+	}
+	jobsPending, err := r.updateJobWorkloads(ctx, *kcb, "")
+	if err != nil {
+		return false, ctrl.Result{}, err
+	}
+	complete, err := r.rolloutWorkloads(ctx, kcb, "")
+	if err != nil || !complete || jobsPending {
+		return false, ctrl.Result{RequeueAfter: RolloutRequeueInterval}, err
+	}
+	return true, ctrl.Result{}, nil
+}
+
+// updateWorkloads sets the config hash annotation on the opted-in workloads selected by the binding and
+// removes it from those no longer selected. An empty hash removes it from all workloads. It returns
+// whether jobs are still waiting to be recreated.
+func (r *KconfigBindingReconciler) updateWorkloads(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) (bool, error) {
+	if err := r.updateRolledWorkloads(ctx, kcb, hash); err != nil {
+		return false, err
+	}
+	return r.updateJobWorkloads(ctx, kcb, hash)
+}
+
+// updateJobWorkloads refreshes the cronJobs and jobs, which have no rollout of their own. It returns
+// whether jobs are still waiting to be recreated.
+func (r *KconfigBindingReconciler) updateJobWorkloads(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) (bool, error) {
+	if err := r.updateCronJobs(ctx, kcb, hash); err != nil {
+		return false, fmt.Errorf("error updating cronjobs: %s", err.Error())
+	}
+	pending, err := r.updateJobs(ctx, kcb, hash)
+	if err != nil {
+		return false, fmt.Errorf("error updating jobs: %s", err.Error())
+	}
+	return pending, nil
+}
+
+// podTemplateOf returns the pod template of a supported workload kind, or nil
+func podTemplateOf(obj client.Object) *corev1.PodTemplateSpec {
+	switch workload := obj.(type) {
+	case *v1.Deployment:
+		return &workload.Spec.Template
+	case *v1.StatefulSet:
+		return &workload.Spec.Template
+	case *v1.DaemonSet:
+		return &workload.Spec.Template
+	case *batchv1.CronJob:
+		return &workload.Spec.JobTemplate.Spec.Template
+	case *batchv1.Job:
+		return &workload.Spec.Template
+	}
+	return nil
+}
+
+// wantedConfigHash returns the config hash an opted-in workload template should carry for the binding
+func wantedConfigHash(selector labels.Selector, template *corev1.PodTemplateSpec, hash string) string {
+	if hash != "" && selector.Matches(labels.Set(template.Labels)) {
+		return hash
+	}
+	return ""
+}
+
+// syncWorkload applies the config hash of the binding to the pod template of an opted-in workload
+// and updates the workload if its template changed. Templates pinned to a revision are left alone.
+func (r *KconfigBindingReconciler) syncWorkload(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, selector labels.Selector, hash string, obj client.Object) error {
+	if obj.GetAnnotations()[AllowTemplateUpdatesAnnotation] != "true" {
+		return nil
+	}
+	template := r.templateOf(obj)
+	if template == nil {
+		return nil
+	}
+	if pinned, err := r.pinned(ctx, kcb, template.Annotations); err != nil || pinned {
+		return err
+	}
+	if !syncConfigHashAnnotation(template, kcb.Name, wantedConfigHash(selector, template, hash)) {
+		return nil
+	}
+	// the template of a configured workload kind is a copy and is written back
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		kind, _ := r.workloadKindOf(u)
+		if err := kind.setPodTemplateAnnotations(u, template.Annotations); err != nil {
+			return fmt.Errorf("error writing pod template annotations: %s", err.Error())
+		}
+	}
+	return r.Update(ctx, obj)
+}
+
+// listRolledWorkloads lists the workloads whose pods are replaced by a rollout when their template
+// changes, i.e. the deployments, statefulSets, daemonSets and configured workload kinds
+func (r *KconfigBindingReconciler) listRolledWorkloads(ctx context.Context, namespace string) ([]client.Object, error) {
+	workloads := make([]client.Object, 0)
+	var deploymentsList v1.DeploymentList
+	if err := r.List(ctx, &deploymentsList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error getting deploymentList: %s", err.Error())
+	}
+	for i := range deploymentsList.Items {
+		workloads = append(workloads, &deploymentsList.Items[i])
+	}
+	var statefulSetList v1.StatefulSetList
+	if err := r.List(ctx, &statefulSetList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error getting statefulSetList: %s", err.Error())
+	}
+	for i := range statefulSetList.Items {
+		workloads = append(workloads, &statefulSetList.Items[i])
+	}
+	var daemonSetList v1.DaemonSetList
+	if err := r.List(ctx, &daemonSetList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error getting daemonSetList: %s", err.Error())
+	}
+	for i := range daemonSetList.Items {
+		workloads = append(workloads, &daemonSetList.Items[i])
+	}
+	custom, err := r.listCustomWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return append(workloads, custom...), nil
+}
+
+// updateRolledWorkloads syncs all rolled workloads at once
+func (r *KconfigBindingReconciler) updateRolledWorkloads(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) error {
+	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	}
+	workloads, err := r.listRolledWorkloads(ctx, kcb.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, obj := range workloads {
+		if err := r.syncWorkload(ctx, kcb, selector, hash, obj); err != nil {
+			return fmt.Errorf("error updating %s: %s", workloadRef(obj), err.Error())
+		}
+	}
+	return nil
+}
+
+// workloadRef identifies a workload as kind/name
+func workloadRef(obj client.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	switch obj.(type) {
+	case *v1.Deployment:
+		kind = "Deployment"
+	case *v1.StatefulSet:
+		kind = "StatefulSet"
+	case *v1.DaemonSet:
+		kind = "DaemonSet"
+	case *batchv1.CronJob:
+		kind = "CronJob"
+	case *batchv1.Job:
+		kind = "Job"
+	}
+	return kind + "/" + obj.GetName()
+}
+
+// updateCronJobs refreshes the job template of cronJobs, which takes effect on their next scheduled run
+func (r *KconfigBindingReconciler) updateCronJobs(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) error {
+	var cronJobList batchv1.CronJobList
+	if err := r.List(ctx, &cronJobList, client.InNamespace(kcb.Namespace)); err != nil {
+		return fmt.Errorf("error getting cronJobList: %s", err.Error())
+	}
+	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	}
+	for _, cronJob := range cronJobList.Items {
+		if err := r.syncWorkload(ctx, kcb, selector, hash, cronJob.DeepCopy()); err != nil {
+			return fmt.Errorf("error updating cronJob: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// updateJobs applies the job refresh policy to running jobs. The pod template of a job is immutable,
+// so a job is either left alone or replaced by a job with the new config hash. The replacement is
+// created under a name derived from its template before the job is deleted, so that a failed step
+// is picked up again on the next reconcile. It returns whether a replacement has to wait for an
+// earlier job of the same name to go away.
+func (r *KconfigBindingReconciler) updateJobs(ctx context.Context, kcb kconfigcontrollerv1beta1.KconfigBinding, hash string) (bool, error) {
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.InNamespace(kcb.Namespace)); err != nil {
+		return false, fmt.Errorf("error getting jobList: %s", err.Error())
+	}
+	selector, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
+	if err != nil {
+		return false, fmt.Errorf("couldn't get selector of kcb: %s", err.Error())
+	}
+	pending := false
+	for _, job := range jobList.Items {
+		if job.Annotations[AllowTemplateUpdatesAnnotation] != "true" || job.DeletionTimestamp != nil || jobFinished(&job) {
+			continue
+		}
+		// jobs of a cronJob pick up its refreshed job template on the next run
+		if owner := v12.GetControllerOf(&job); owner != nil && owner.Kind == "CronJob" {
+			continue
+		}
+		policy := r.JobRefreshPolicy
+		if val, ok := job.Annotations[JobRefreshPolicyAnnotation]; ok {
+			policy = val
+		}
+		if !strings.EqualFold(policy, RecreateJobRefreshPolicy) {
+			continue
+		}
+		if pinned, err := r.pinned(ctx, kcb, job.Spec.Template.Annotations); err != nil {
+			return false, err
+		} else if pinned {
+			continue
+		}
+		recreated := recreatedJob(&job)
+		if !syncConfigHashAnnotation(&recreated.Spec.Template, kcb.Name, wantedConfigHash(selector, &job.Spec.Template, hash)) {
+			continue
+		}
+		recreated.Name = recreatedJobName(recreated)
+		if err := r.Create(ctx, recreated); errors.IsAlreadyExists(err) {
+			// a replacement created by an earlier reconcile, or a deleted job that still lingers
+			var existing batchv1.Job
+			if err := r.Get(ctx, client.ObjectKeyFromObject(recreated), &existing); client.IgnoreNotFound(err) != nil {
+				return false, fmt.Errorf("error getting job: %s", err.Error())
+			} else if err != nil || existing.DeletionTimestamp != nil {
+				pending = true
+				continue
+			}
+		} else if err != nil {
+			return false, fmt.Errorf("error recreating job: %s", err.Error())
+		}
+		propagation := v12.DeletePropagationBackground
+		if err := r.Delete(ctx, &job, &client.DeleteOptions{PropagationPolicy: &propagation}); client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("error deleting job: %s", err.Error())
+		}
+	}
+	return pending, nil
+}
+
+func jobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// recreatedJob returns a copy of the job that can be created in its place, without the server
+// populated metadata and labels and, unless the job manages its own selector, without the generated
+// selector. The name of the original job is recorded for naming later replacements.
+func recreatedJob(job *batchv1.Job) *batchv1.Job {
+	recreated := &batchv1.Job{
+		ObjectMeta: v12.ObjectMeta{
+			Namespace:       job.Namespace,
+			Name:            job.Name,
+			Labels:          make(map[string]string, len(job.Labels)),
+			Annotations:     make(map[string]string, len(job.Annotations)+1),
+			OwnerReferences: job.OwnerReferences,
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+	for key, value := range job.Labels {
+		recreated.Labels[key] = value
+	}
+	for key, value := range job.Annotations {
+		recreated.Annotations[key] = value
+	}
+	if _, ok := recreated.Annotations[RecreatedJobAnnotation]; !ok {
+		recreated.Annotations[RecreatedJobAnnotation] = job.Name
+	}
+	for _, generated := range []string{"controller-uid", "batch.kubernetes.io/controller-uid"} {
+		delete(recreated.Labels, generated)
+	}
+	if job.Spec.ManualSelector == nil || !*job.Spec.ManualSelector {
+		recreated.Spec.Selector = nil
+		for _, generated := range []string{"controller-uid", "batch.kubernetes.io/controller-uid", "job-name", "batch.kubernetes.io/job-name"} {
+			delete(recreated.Spec.Template.Labels, generated)
+		}
+	}
+	return recreated
+}
+
+// recreatedJobName names a replacement job after the original job and a hash of its template
+// annotations, so that creating it again for the same config finds the existing replacement
+func recreatedJobName(job *batchv1.Job) string {
+	hash := sha256.New()
+	for _, key := range sortedKeys(job.Spec.Template.Annotations) {
+		for _, field := range []string{key, job.Spec.Template.Annotations[key]} {
+			_ = binary.Write(hash, binary.BigEndian, uint64(len(field)))
+			hash.Write([]byte(field))
+		}
+	}
+	base := job.Annotations[RecreatedJobAnnotation]
+	if len(base) > validation.DNS1123LabelMaxLength-RevisionHashLength-1 {
+		base = strings.TrimRight(base[:validation.DNS1123LabelMaxLength-RevisionHashLength-1], "-.")
+	}
+	return fmt.Sprintf("%s-%s", base, hex.EncodeToString(hash.Sum(nil))[:RevisionHashLength])
+}
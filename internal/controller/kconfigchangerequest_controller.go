@@ -0,0 +1,134 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// KconfigChangeRequestReconciler applies approved KconfigChangeRequests to their Kconfig
+type KconfigChangeRequestReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigchangerequests,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kconfigcontroller.atteg.com,resources=kconfigchangerequests/status,verbs=get;update;patch
+
+// Reconcile applies the EnvConfigs or the deletion of an approved change request to its Kconfig once.
+// The requester and approver are recorded by the admission webhook of change requests, the approval is
+// checked again here in case the webhook is bypassed.
+func (r *KconfigChangeRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("kconfigchangerequest", req.NamespacedName)
+
+	var kcr kconfigcontrollerv1beta1.KconfigChangeRequest
+	if err := r.Get(ctx, req.NamespacedName, &kcr); err != nil {
+		// Not Found is disregarded and ends reconciliation
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if kcr.Status.Phase == ChangeRequestAppliedPhase || kcr.Status.Phase == ChangeRequestFailedPhase {
+		return ctrl.Result{}, nil
+	}
+
+	status := kconfigcontrollerv1beta1.KconfigChangeRequestStatus{Phase: ChangeRequestPendingPhase}
+	if kcr.Spec.Approved {
+		applyErr := r.applyChangeRequest(ctx, &kcr)
+		switch {
+		case applyErr == nil:
+			now := metav1.Now()
+			status = kconfigcontrollerv1beta1.KconfigChangeRequestStatus{Phase: ChangeRequestAppliedPhase, AppliedTime: &now}
+		case errors.IsConflict(applyErr):
+			return ctrl.Result{}, applyErr
+		default:
+			status = kconfigcontrollerv1beta1.KconfigChangeRequestStatus{Phase: ChangeRequestFailedPhase, Message: applyErr.Error()}
+			r.Recorder.Event(&kcr, WarningEventType, ChangeRequestFailedEvent, applyErr.Error())
+		}
+	}
+	if status != kcr.Status {
+		kcr.Status = status
+		if err := r.Status().Update(ctx, &kcr); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error updating kconfigChangeRequest status: %s", err.Error())
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// applyChangeRequest replaces the EnvConfigs of the Kconfig with those of the change request, under a
+// field manager naming the change request so that it shows as the author of the resulting revision,
+// or deletes the Kconfig.
+// A change request based on an earlier generation of the Kconfig would revert the changes since.
+func (r *KconfigChangeRequestReconciler) applyChangeRequest(ctx context.Context, kcr *kconfigcontrollerv1beta1.KconfigChangeRequest) error {
+	if kcr.Spec.RequestedBy == "" || kcr.Spec.ApprovedBy == "" || kcr.Spec.ApprovedBy == kcr.Spec.RequestedBy {
+		return fmt.Errorf("change request is not approved by a user other than its requester")
+	}
+	var kc kconfigcontrollerv1beta1.Kconfig
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kcr.Namespace, Name: kcr.Spec.KconfigName}, &kc); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("kconfig %s not found", kcr.Spec.KconfigName)
+		}
+		return fmt.Errorf("error getting kconfig: %s", err.Error())
+	}
+	if kc.Generation != kcr.Spec.BaseGeneration {
+		return fmt.Errorf("kconfig %s changed since the change request was based on generation %d, it is at generation %d", kc.Name, kcr.Spec.BaseGeneration, kc.Generation)
+	}
+	if kcr.Spec.Delete {
+		// the preconditions keep a concurrent change from being deleted along
+		preconditions := client.Preconditions{UID: &kc.UID, ResourceVersion: &kc.ResourceVersion}
+		if err := r.Delete(ctx, &kc, preconditions); err != nil {
+			if errors.IsConflict(err) {
+				return err
+			}
+			return fmt.Errorf("error deleting kconfig: %s", err.Error())
+		}
+		r.Recorder.Eventf(kcr, NormalEventType, ChangeRequestAppliedEvent, "deleted kconfig %s requested by %s and approved by %s", kc.Name, kcr.Spec.RequestedBy, kcr.Spec.ApprovedBy)
+		return nil
+	}
+	kc.Spec.EnvConfigs = make([]kconfigcontrollerv1beta1.EnvConfig, 0, len(kcr.Spec.EnvConfigs))
+	for _, ec := range kcr.Spec.EnvConfigs {
+		kc.Spec.EnvConfigs = append(kc.Spec.EnvConfigs, *ec.DeepCopy())
+	}
+	if err := r.Update(ctx, &kc, client.FieldOwner(ChangeRequestFieldManagerPrefix+kcr.Name)); err != nil {
+		if errors.IsConflict(err) {
+			return err
+		}
+		return fmt.Errorf("error updating kconfig: %s", err.Error())
+	}
+	r.Recorder.Eventf(&kc, NormalEventType, ChangeRequestAppliedEvent, "applied kconfigChangeRequest %s requested by %s and approved by %s", kcr.Name, kcr.Spec.RequestedBy, kcr.Spec.ApprovedBy)
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KconfigChangeRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kconfigcontrollerv1beta1.KconfigChangeRequest{}).
+		Named("kconfigchangerequest").
+		Complete(r)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+var _ = Describe("KconfigChangeRequest Controller", func() {
+	Context("When reconciling a resource", func() {
+		const kconfigName = "test-protected"
+
+		ctx := context.Background()
+
+		kconfigNamespacedName := types.NamespacedName{
+			Name:      kconfigName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating a protected Kconfig")
+			value := "info"
+			resource := &kconfigcontrollerv1beta1.Kconfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kconfigName,
+					Namespace: "default",
+					Labels:    map[string]string{ProtectedKconfigLabel: "true"},
+				},
+				Spec: kconfigcontrollerv1beta1.KconfigSpec{
+					EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{
+						{Type: ValueEnvConfigType, Key: "LOG_LEVEL", Value: &value},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &kconfigcontrollerv1beta1.Kconfig{}
+			err := k8sClient.Get(ctx, kconfigNamespacedName, resource)
+			if errors.IsNotFound(err) {
+				// deleted by a change request
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+
+		It("should only apply change requests approved by another user", func() {
+			controllerReconciler := &KconfigChangeRequestReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			value := "debug"
+			changeRequest := func(name, approvedBy string, baseGeneration int64) *kconfigcontrollerv1beta1.KconfigChangeRequest {
+				kcr := &kconfigcontrollerv1beta1.KconfigChangeRequest{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec: kconfigcontrollerv1beta1.KconfigChangeRequestSpec{
+						KconfigName: kconfigName,
+						EnvConfigs: []kconfigcontrollerv1beta1.EnvConfig{
+							{Type: ValueEnvConfigType, Key: "LOG_LEVEL", Value: &value},
+						},
+						Approved:       true,
+						RequestedBy:    "alice",
+						ApprovedBy:     approvedBy,
+						BaseGeneration: baseGeneration,
+					},
+				}
+				Expect(k8sClient.Create(ctx, kcr)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, kcr)).To(Succeed())
+				})
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, kcr)).To(Succeed())
+				return kcr
+			}
+			kc := &kconfigcontrollerv1beta1.Kconfig{}
+			Expect(k8sClient.Get(ctx, kconfigNamespacedName, kc)).To(Succeed())
+			base := kc.Generation
+
+			By("failing a change request approved by its requester")
+			kcr := changeRequest("test-self-approved", "alice", base)
+			Expect(kcr.Status.Phase).To(Equal(ChangeRequestFailedPhase))
+			Expect(k8sClient.Get(ctx, kconfigNamespacedName, kc)).To(Succeed())
+			Expect(*kc.Spec.EnvConfigs[0].Value).To(Equal("info"))
+
+			By("applying a change request approved by another user")
+			kcr = changeRequest("test-approved", "bob", base)
+			Expect(kcr.Status.Phase).To(Equal(ChangeRequestAppliedPhase))
+			Expect(kcr.Status.AppliedTime).NotTo(BeNil())
+			Expect(k8sClient.Get(ctx, kconfigNamespacedName, kc)).To(Succeed())
+			Expect(*kc.Spec.EnvConfigs[0].Value).To(Equal("debug"))
+
+			By("failing a change request based on an earlier generation")
+			value = "warn"
+			kcr = changeRequest("test-stale", "bob", base)
+			Expect(kcr.Status.Phase).To(Equal(ChangeRequestFailedPhase))
+			Expect(kcr.Status.Message).To(ContainSubstring("changed since"))
+			Expect(k8sClient.Get(ctx, kconfigNamespacedName, kc)).To(Succeed())
+			Expect(*kc.Spec.EnvConfigs[0].Value).To(Equal("debug"))
+		})
+
+		It("should delete the kconfig of an approved deletion change request", func() {
+			controllerReconciler := &KconfigChangeRequestReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			kc := &kconfigcontrollerv1beta1.Kconfig{}
+			Expect(k8sClient.Get(ctx, kconfigNamespacedName, kc)).To(Succeed())
+			kcr := &kconfigcontrollerv1beta1.KconfigChangeRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-delete", Namespace: "default"},
+				Spec: kconfigcontrollerv1beta1.KconfigChangeRequestSpec{
+					KconfigName:    kconfigName,
+					Delete:         true,
+					Approved:       true,
+					RequestedBy:    "alice",
+					ApprovedBy:     "bob",
+					BaseGeneration: kc.Generation,
+				},
+			}
+			Expect(k8sClient.Create(ctx, kcr)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, kcr)).To(Succeed())
+			})
+			nn := types.NamespacedName{Namespace: "default", Name: kcr.Name}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: nn})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nn, kcr)).To(Succeed())
+			Expect(kcr.Status.Phase).To(Equal(ChangeRequestAppliedPhase))
+			err = k8sClient.Get(ctx, kconfigNamespacedName, kc)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,71 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	kconfigcontrollerv1beta1 "github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+)
+
+// parseCronSchedule parses a standard five field cron expression or a descriptor such as @daily. The
+// time zone is given by the window, and @every intervals have no fixed starts, so neither is accepted.
+func parseCronSchedule(spec string) (*cron.SpecSchedule, error) {
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		return nil, fmt.Errorf("invalid cron schedule %q: time zones are set by timeZone", spec)
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron schedule %q: %s", spec, err.Error())
+	}
+	specSchedule, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		return nil, fmt.Errorf("invalid cron schedule %q: intervals are not supported", spec)
+	}
+	return specSchedule, nil
+}
+
+// maintenanceWindowOpen reports whether one of the windows is open at now, and otherwise when the
+// next one opens, which is the zero time if none does
+func maintenanceWindowOpen(windows []kconfigcontrollerv1beta1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	var next time.Time
+	for _, window := range windows {
+		schedule, err := parseCronSchedule(window.Schedule)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		loc := time.UTC
+		if window.TimeZone != "" {
+			if loc, err = time.LoadLocation(window.TimeZone); err != nil {
+				return false, time.Time{}, fmt.Errorf("invalid time zone %q: %s", window.TimeZone, err.Error())
+			}
+		}
+		local := now.In(loc)
+		// the first start after now-duration is within the window if it is not after now
+		if start := schedule.Next(local.Add(-window.Duration.Duration)); !start.IsZero() && !start.After(local) {
+			return true, time.Time{}, nil
+		}
+		if start := schedule.Next(local); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return false, next, nil
+}
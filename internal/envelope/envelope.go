@@ -0,0 +1,154 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package envelope encrypts values for Kconfig manifests against the controller's RSA public key.
+//
+// A value is sealed with a random AES-256-GCM data key, the data key is wrapped with RSA-OAEP (SHA-256)
+// and the result is encoded as "<Prefix><base64(keyLen | wrappedKey | nonce | ciphertext)>".
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// Prefix identifies the envelope format and version
+const Prefix = "kcenc:v1:"
+
+const dataKeySize = 32
+
+// Encrypt seals plaintext for the holder of the private key matching pub
+func Encrypt(pub *rsa.PublicKey, plaintext []byte) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("error generating data key: %s", err.Error())
+	}
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, dataKey, nil)
+	if err != nil {
+		return "", fmt.Errorf("error wrapping data key: %s", err.Error())
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %s", err.Error())
+	}
+
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(wrappedKey)))
+	out = append(out, wrappedKey...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, plaintext, nil)
+	return Prefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt opens a value produced by Encrypt
+func Decrypt(priv *rsa.PrivateKey, value string) ([]byte, error) {
+	if !strings.HasPrefix(value, Prefix) {
+		return nil, fmt.Errorf("value is not a %s envelope", strings.TrimSuffix(Prefix, ":"))
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding envelope: %s", err.Error())
+	}
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("envelope is truncated")
+	}
+	keyLen := int(binary.BigEndian.Uint16(raw))
+	raw = raw[2:]
+	if len(raw) < keyLen {
+		return nil, fmt.Errorf("envelope is truncated")
+	}
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), nil, priv, raw[:keyLen], nil)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %s", err.Error())
+	}
+	raw = raw[keyLen:]
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, fmt.Errorf("envelope is truncated")
+	}
+	plaintext, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting value: %s", err.Error())
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %s", err.Error())
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating gcm: %s", err.Error())
+	}
+	return gcm, nil
+}
+
+// ParsePrivateKeyPEM reads a PKCS#1 or PKCS#8 encoded RSA private key
+func ParsePrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %s", err.Error())
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA private key but got %T", key)
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKeyPEM reads a PKIX or PKCS#1 encoded RSA public key
+func ParsePublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %s", err.Error())
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA public key but got %T", key)
+	}
+	return rsaKey, nil
+}
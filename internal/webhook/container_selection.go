@@ -0,0 +1,148 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Container selectors select containers by these labels
+const (
+	ContainerNameLabel = "name"
+	// ContainerTypeLabel is the type of the container. Selectors not selecting by type only select
+	// main containers.
+	ContainerTypeLabel = "type"
+	MainContainerType  = "main"
+	InitContainerType  = "init"
+	// SidecarContainerType are init containers restarted for the life of the pod
+	SidecarContainerType = "sidecar"
+	// ContainerIndexLabel is the index of the container among the containers of its type
+	ContainerIndexLabel = "index"
+	// ContainerImageLabel is the last path segment of the image repository, e.g. openjdk
+	ContainerImageLabel = "image"
+	// ContainerImageRepositoryLabel is the image repository with / and : replaced by _,
+	// e.g. docker.io_library_openjdk
+	ContainerImageRepositoryLabel = "image-repository"
+	ContainerImageTagLabel        = "image-tag"
+
+	// ContainerLabelsAnnotationPrefix is followed by the container name in pod annotations declaring
+	// further container labels as comma separated key=value pairs
+	ContainerLabelsAnnotationPrefix = "kconfigcontroller.atteg.com/container-labels."
+)
+
+// podContainer is a main or init container of a pod with the labels it is selected by
+type podContainer struct {
+	Container *v1.Container
+	Type      string
+	Labels    labels.Set
+}
+
+// podContainers returns the init containers and then the main containers of a pod
+func podContainers(pod *v1.Pod) []podContainer {
+	containers := make([]podContainer, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	// init containers and sidecars are indexed separately, as they are of different types
+	indexes := make(map[string]int)
+	for i := range pod.Spec.InitContainers {
+		container := &pod.Spec.InitContainers[i]
+		containerType := InitContainerType
+		if container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways {
+			containerType = SidecarContainerType
+		}
+		containers = append(containers, newPodContainer(pod, container, containerType, indexes[containerType]))
+		indexes[containerType]++
+	}
+	for i := range pod.Spec.Containers {
+		containers = append(containers, newPodContainer(pod, &pod.Spec.Containers[i], MainContainerType, i))
+	}
+	return containers
+}
+
+func newPodContainer(pod *v1.Pod, container *v1.Container, containerType string, index int) podContainer {
+	set := labels.Set{}
+	if declared, ok := pod.Annotations[ContainerLabelsAnnotationPrefix+container.Name]; ok {
+		parsed, err := labels.ConvertSelectorToLabelsMap(declared)
+		if err != nil {
+			podConfigInjectorLog.Error(err, fmt.Sprintf("ignoring malformed container labels of %s", container.Name))
+		}
+		for key, value := range parsed {
+			set[key] = value
+		}
+	}
+	set[ContainerNameLabel] = container.Name
+	set[ContainerTypeLabel] = containerType
+	set[ContainerIndexLabel] = strconv.Itoa(index)
+	delete(set, ContainerImageLabel)
+	delete(set, ContainerImageRepositoryLabel)
+	delete(set, ContainerImageTagLabel)
+	repository, tag := splitImage(container.Image)
+	setLabelValue(set, ContainerImageLabel, repository[strings.LastIndex(repository, "/")+1:])
+	setLabelValue(set, ContainerImageRepositoryLabel, strings.NewReplacer("/", "_", ":", "_").Replace(repository))
+	setLabelValue(set, ContainerImageTagLabel, tag)
+	return podContainer{Container: container, Type: containerType, Labels: set}
+}
+
+// splitImage splits an image reference into its repository and tag, dropping any digest
+func splitImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// setLabelValue sets a label unless its value is not a valid label value, which no selector could match
+func setLabelValue(set labels.Set, key, value string) {
+	if value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+		set[key] = value
+	}
+}
+
+// selectsContainer reports whether a container selector selects the container
+func selectsContainer(labelSelector *v12.LabelSelector, c podContainer) (bool, error) {
+	selector, err := v12.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return false, err
+	}
+	if c.Type != MainContainerType && !selectsByType(labelSelector) {
+		return false, nil
+	}
+	return selector.Matches(c.Labels), nil
+}
+
+// selectsByType reports whether a container selector has a requirement on the container type
+func selectsByType(labelSelector *v12.LabelSelector) bool {
+	if labelSelector == nil {
+		return false
+	}
+	if _, ok := labelSelector.MatchLabels[ContainerTypeLabel]; ok {
+		return true
+	}
+	for _, requirement := range labelSelector.MatchExpressions {
+		if requirement.Key == ContainerTypeLabel {
+			return true
+		}
+	}
+	return false
+}
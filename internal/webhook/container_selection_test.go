@@ -0,0 +1,105 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestSelectsContainer(t *testing.T) {
+	always := v1.ContainerRestartPolicyAlways
+	pod := &v1.Pod{Spec: v1.PodSpec{
+		InitContainers: []v1.Container{{Name: "migrate"}, {Name: "proxy", RestartPolicy: &always}},
+		Containers:     []v1.Container{{Name: "app"}},
+	}}
+	containers := podContainers(pod)
+	if len(containers) != 3 || containers[0].Type != InitContainerType || containers[1].Type != SidecarContainerType || containers[2].Type != MainContainerType {
+		t.Fatalf("unexpected pod containers %v", containers)
+	}
+	// init containers and sidecars are indexed among their own type
+	indexed := podContainers(&v1.Pod{Spec: v1.PodSpec{InitContainers: []v1.Container{
+		{Name: "migrate"}, {Name: "proxy", RestartPolicy: &always}, {Name: "seed"}, {Name: "log", RestartPolicy: &always},
+	}}})
+	for i, expected := range []string{"0", "0", "1", "1"} {
+		if index := indexed[i].Labels[ContainerIndexLabel]; index != expected {
+			t.Errorf("expected index %s of %s but got %s", expected, indexed[i].Container.Name, index)
+		}
+	}
+
+	cases := []struct {
+		selector *v12.LabelSelector
+		selected []bool
+	}{
+		{&v12.LabelSelector{}, []bool{false, false, true}},
+		{&v12.LabelSelector{MatchLabels: map[string]string{ContainerTypeLabel: InitContainerType}}, []bool{true, false, false}},
+		{&v12.LabelSelector{MatchExpressions: []v12.LabelSelectorRequirement{{
+			Key: ContainerTypeLabel, Operator: v12.LabelSelectorOpIn, Values: []string{SidecarContainerType, MainContainerType},
+		}}}, []bool{false, true, true}},
+		{&v12.LabelSelector{MatchExpressions: []v12.LabelSelectorRequirement{{Key: ContainerTypeLabel, Operator: v12.LabelSelectorOpExists}}}, []bool{true, true, true}},
+	}
+	for _, c := range cases {
+		for i, container := range containers {
+			selected, err := selectsContainer(c.selector, container)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if selected != c.selected[i] {
+				t.Errorf("expected %v selecting %s to be %t", c.selector, container.Container.Name, c.selected[i])
+			}
+		}
+	}
+}
+
+func TestContainerLabels(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: v12.ObjectMeta{Annotations: map[string]string{
+			ContainerLabelsAnnotationPrefix + "api": "runtime=jvm,name=other",
+		}},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "web", Image: "nginx"},
+			{Name: "api", Image: "registry.example.com:5000/team/openjdk:21-jre@sha256:abc"},
+		}},
+	}
+	containers := podContainers(pod)
+	expected := labels.Set{
+		ContainerNameLabel:            "api",
+		ContainerTypeLabel:            MainContainerType,
+		ContainerIndexLabel:           "1",
+		ContainerImageLabel:           "openjdk",
+		ContainerImageRepositoryLabel: "registry.example.com_5000_team_openjdk",
+		ContainerImageTagLabel:        "21-jre",
+		"runtime":                     "jvm",
+	}
+	if !reflect.DeepEqual(containers[1].Labels, expected) {
+		t.Errorf("expected %v but got %v", expected, containers[1].Labels)
+	}
+	if _, tagged := containers[0].Labels[ContainerImageTagLabel]; tagged || containers[0].Labels[ContainerImageLabel] != "nginx" {
+		t.Errorf("unexpected labels of untagged image %v", containers[0].Labels)
+	}
+
+	jvm := &v12.LabelSelector{MatchLabels: map[string]string{"runtime": "jvm"}}
+	for i, expected := range []bool{false, true} {
+		if selected, err := selectsContainer(jvm, containers[i]); err != nil || selected != expected {
+			t.Errorf("expected jvm selector selecting %s to be %t", containers[i].Container.Name, expected)
+		}
+	}
+}
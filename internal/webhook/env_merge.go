@@ -0,0 +1,100 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Env precedence decides between an env declared by a container and an injected env of the same name
+const (
+	// BindingWinsEnvPrecedence replaces the declared env with the injected one
+	BindingWinsEnvPrecedence = "BindingWins"
+	// ContainerWinsEnvPrecedence keeps the declared env and drops the injected one
+	ContainerWinsEnvPrecedence = "ContainerWins"
+	// RejectEnvPrecedence rejects the pod
+	RejectEnvPrecedence = "Reject"
+)
+
+// ValidEnvPrecedence reports whether precedence is one of the env precedences
+func ValidEnvPrecedence(precedence string) bool {
+	switch precedence {
+	case BindingWinsEnvPrecedence, ContainerWinsEnvPrecedence, RejectEnvPrecedence:
+		return true
+	}
+	return false
+}
+
+// boundEnvs are the envs a binding injects into a container
+type boundEnvs struct {
+	Binding string
+	Envs    []v1.EnvVar
+}
+
+// mergeEnvs merges the envs of the bindings, in ascending order of precedence, into the declared envs
+// of a container so that each name appears once. Among bindings the later one wins, as does the later
+// of duplicate envs within a binding or within the declared envs. Declared envs keep their position,
+// so that envs referencing them with $(NAME) still see them, and injected envs not declared follow in
+// the order they first appear.
+// Declared envs of owned names were injected by an earlier invocation. They are refreshed in place
+// regardless of the precedence, or dropped when no longer injected, so that merging is idempotent.
+// The names of the merged envs holding injected values are returned with them.
+func mergeEnvs(declared []v1.EnvVar, owned map[string]bool, bindings []boundEnvs, precedence string) ([]v1.EnvVar, []string, error) {
+	injected := make(map[string]v1.EnvVar)
+	source := make(map[string]string)
+	order := make([]string, 0)
+	for _, binding := range bindings {
+		for _, env := range binding.Envs {
+			if _, ok := injected[env.Name]; !ok {
+				order = append(order, env.Name)
+			}
+			injected[env.Name] = env
+			source[env.Name] = binding.Binding
+		}
+	}
+
+	merged := make([]v1.EnvVar, 0, len(declared)+len(order))
+	position := make(map[string]int)
+	for _, env := range declared {
+		if _, ok := injected[env.Name]; owned[env.Name] && !ok {
+			continue
+		}
+		if i, ok := position[env.Name]; ok {
+			merged[i] = env
+			continue
+		}
+		position[env.Name] = len(merged)
+		merged = append(merged, env)
+	}
+	names := make([]string, 0, len(order))
+	for _, name := range order {
+		i, ok := position[name]
+		switch {
+		case !ok:
+			merged = append(merged, injected[name])
+		case owned[name] || precedence == BindingWinsEnvPrecedence:
+			merged[i] = injected[name]
+		case precedence == RejectEnvPrecedence:
+			return nil, nil, fmt.Errorf("env %s is declared by the container and injected by kconfigbinding %s", name, source[name])
+		default:
+			continue
+		}
+		names = append(names, name)
+	}
+	return merged, names, nil
+}
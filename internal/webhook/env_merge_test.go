@@ -0,0 +1,94 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestMergeEnvs(t *testing.T) {
+	declared := []v1.EnvVar{{Name: "A", Value: "container"}, {Name: "B", Value: "container"}}
+	bindings := []boundEnvs{
+		{Binding: "low", Envs: []v1.EnvVar{{Name: "C", Value: "low"}, {Name: "B", Value: "low"}}},
+		{Binding: "high", Envs: []v1.EnvVar{{Name: "C", Value: "high"}, {Name: "D", Value: "high"}}},
+	}
+
+	merged, _, err := mergeEnvs(declared, nil, bindings, BindingWinsEnvPrecedence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1.EnvVar{{Name: "A", Value: "container"}, {Name: "B", Value: "low"}, {Name: "C", Value: "high"}, {Name: "D", Value: "high"}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v but got %v", expected, merged)
+	}
+
+	merged, _, err = mergeEnvs(declared, nil, bindings, ContainerWinsEnvPrecedence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []v1.EnvVar{{Name: "A", Value: "container"}, {Name: "B", Value: "container"}, {Name: "C", Value: "high"}, {Name: "D", Value: "high"}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v but got %v", expected, merged)
+	}
+
+	if _, _, err := mergeEnvs(declared, nil, bindings, RejectEnvPrecedence); err == nil {
+		t.Error("expected conflicting env to be rejected")
+	}
+	if _, _, err := mergeEnvs(nil, nil, bindings, RejectEnvPrecedence); err != nil {
+		t.Errorf("expected envs without declared envs to merge but got %s", err.Error())
+	}
+
+	for _, precedence := range []string{BindingWinsEnvPrecedence, ContainerWinsEnvPrecedence} {
+		first, names, err := mergeEnvs(declared, nil, bindings, precedence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owned := make(map[string]bool)
+		for _, name := range names {
+			owned[name] = true
+		}
+		again, _, err := mergeEnvs(first, owned, bindings, precedence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, first) {
+			t.Errorf("expected %s merge to be idempotent but got %v after %v", precedence, again, first)
+		}
+	}
+	first, names, err := mergeEnvs(nil, nil, bindings, RejectEnvPrecedence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owned := map[string]bool{}
+	for _, name := range names {
+		owned[name] = true
+	}
+	if again, _, err := mergeEnvs(first, owned, bindings, RejectEnvPrecedence); err != nil || !reflect.DeepEqual(again, first) {
+		t.Errorf("expected reinvocation to keep %v but got %v, %v", first, again, err)
+	}
+	again, _, err := mergeEnvs(first, owned, bindings[:1], BindingWinsEnvPrecedence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []v1.EnvVar{{Name: "C", Value: "low"}, {Name: "B", Value: "low"}}
+	if !reflect.DeepEqual(again, expected) {
+		t.Errorf("expected envs no longer injected to be dropped, expected %v but got %v", expected, again)
+	}
+}
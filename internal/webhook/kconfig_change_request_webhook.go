@@ -0,0 +1,151 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/controller"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func SetupKconfigChangeRequestWebhookWithManager(mgr ctrl.Manager) error {
+	approval := &KconfigChangeRequestApproval{Client: mgr.GetClient()}
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1beta1.KconfigChangeRequest{}).
+		WithDefaulter(approval).
+		WithValidator(approval).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-kconfigcontroller-atteg-com-v1beta1-kconfigchangerequest,mutating=true,failurePolicy=fail,sideEffects=None,groups=kconfigcontroller.atteg.com,resources=kconfigchangerequests,verbs=create;update,versions=v1beta1,name=change-request-approval.kconfigcontroller.aeg.cloud,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-kconfigcontroller-atteg-com-v1beta1-kconfigchangerequest,mutating=false,failurePolicy=fail,sideEffects=None,groups=kconfigcontroller.atteg.com,resources=kconfigchangerequests,verbs=create;update,versions=v1beta1,name=change-request-validation.kconfigcontroller.aeg.cloud,admissionReviewVersions=v1
+
+// KconfigChangeRequestApproval records the requester and the approver of a KconfigChangeRequest from
+// the user of the admission request, and the generation of the Kconfig it is based on, and rejects
+// approvals by the requester. Change requests of a missing Kconfig are rejected.
+type KconfigChangeRequestApproval struct {
+	Client client.Client
+}
+
+var _ webhook.CustomDefaulter = &KconfigChangeRequestApproval{}
+var _ webhook.CustomValidator = &KconfigChangeRequestApproval{}
+
+func (r *KconfigChangeRequestApproval) Default(ctx context.Context, obj runtime.Object) error {
+	kcr, ok := obj.(*v1beta1.KconfigChangeRequest)
+	if !ok {
+		return fmt.Errorf("expected a KconfigChangeRequest object but got %T", obj)
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get admission request: %s", err.Error())
+	}
+	user := req.UserInfo.Username
+	if req.Operation == admissionv1.Create {
+		kcr.Spec.RequestedBy = user
+		kcr.Spec.ApprovedBy = ""
+		if kcr.Spec.Approved {
+			kcr.Spec.ApprovedBy = user
+		}
+		var kc v1beta1.Kconfig
+		nn := types.NamespacedName{Namespace: kcr.Namespace, Name: kcr.Spec.KconfigName}
+		if err := r.Client.Get(ctx, nn, &kc); err != nil {
+			if errors.IsNotFound(err) {
+				return fmt.Errorf("kconfig %s not found", kcr.Spec.KconfigName)
+			}
+			return fmt.Errorf("could not get kconfig: %s", err.Error())
+		}
+		if kcr.Spec.BaseGeneration == 0 {
+			// the controller rewrites the spec of a mutating kconfig as it processes it, which the change
+			// request must be based on so that it doesn't go stale right away
+			if kc.Status.ObservedGeneration != kc.Generation {
+				return fmt.Errorf("kconfig %s is being processed by the controller, retry once it is processed", kc.Name)
+			}
+			kcr.Spec.BaseGeneration = kc.Generation
+		}
+		return nil
+	}
+	var old v1beta1.KconfigChangeRequest
+	if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+		return fmt.Errorf("could not decode old kconfigChangeRequest: %s", err.Error())
+	}
+	kcr.Spec.RequestedBy = old.Spec.RequestedBy
+	kcr.Spec.BaseGeneration = old.Spec.BaseGeneration
+	switch {
+	case !kcr.Spec.Approved:
+		kcr.Spec.ApprovedBy = ""
+	case old.Spec.Approved:
+		kcr.Spec.ApprovedBy = old.Spec.ApprovedBy
+	default:
+		kcr.Spec.ApprovedBy = user
+	}
+	return nil
+}
+
+func (r *KconfigChangeRequestApproval) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	kcr, ok := obj.(*v1beta1.KconfigChangeRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a KconfigChangeRequest object but got %T", obj)
+	}
+	for _, ec := range kcr.Spec.EnvConfigs {
+		if strings.ToLower(ec.Type) == "secret" && ec.Value != nil {
+			return nil, fmt.Errorf("secret value of %s must be given as encryptedValue or secretKeyRef", ec.Key)
+		}
+	}
+	return nil, validateApproval(kcr)
+}
+
+func (r *KconfigChangeRequestApproval) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*v1beta1.KconfigChangeRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a KconfigChangeRequest object but got %T", oldObj)
+	}
+	kcr, ok := newObj.(*v1beta1.KconfigChangeRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a KconfigChangeRequest object but got %T", newObj)
+	}
+	if kcr.Spec.KconfigName != old.Spec.KconfigName || kcr.Spec.Delete != old.Spec.Delete ||
+		!equality.Semantic.DeepEqual(kcr.Spec.EnvConfigs, old.Spec.EnvConfigs) {
+		return nil, fmt.Errorf("kconfigName, envConfigs and delete of a change request are immutable")
+	}
+	if old.Status.Phase == controller.ChangeRequestAppliedPhase && !kcr.Spec.Approved {
+		return nil, fmt.Errorf("applied change requests cannot be unapproved")
+	}
+	return nil, validateApproval(kcr)
+}
+
+func (r *KconfigChangeRequestApproval) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateApproval rejects change requests approved by their requester
+func validateApproval(kcr *v1beta1.KconfigChangeRequest) error {
+	if kcr.Spec.Approved && kcr.Spec.ApprovedBy == kcr.Spec.RequestedBy {
+		return fmt.Errorf("change request must be approved by a user other than %s who requested it", kcr.Spec.RequestedBy)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/controller"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func SetupKconfigProtectionWithManager(mgr ctrl.Manager, controllerUsername string) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1beta1.Kconfig{}).
+		WithValidator(
+			&KconfigProtection{
+				ControllerUsername: controllerUsername,
+			},
+		).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-kconfigcontroller-atteg-com-v1beta1-kconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=kconfigcontroller.atteg.com,resources=kconfigs,verbs=update;delete,versions=v1beta1,name=protection.kconfigcontroller.aeg.cloud,admissionReviewVersions=v1
+
+// namespaceControllerUsernames are the users the namespace controller deletes the objects of a
+// terminating namespace as, with and without service account credentials per controller
+var namespaceControllerUsernames = map[string]bool{
+	"system:serviceaccount:kube-system:namespace-controller": true,
+	"system:kube-controller-manager":                         true,
+}
+
+// KconfigProtection rejects direct edits to Kconfigs labeled as protected, or being labeled so. Their
+// spec is only changed by the controller, applying approved KconfigChangeRequests. Protected Kconfigs
+// are deleted by the controller applying an approved KconfigChangeRequest, or along with their
+// namespace.
+type KconfigProtection struct {
+	// ControllerUsername is the user of the controller, whose requests are allowed
+	ControllerUsername string
+}
+
+var _ webhook.CustomValidator = &KconfigProtection{}
+
+func (r *KconfigProtection) ValidateCreate(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (r *KconfigProtection) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*v1beta1.Kconfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a Kconfig object but got %T", oldObj)
+	}
+	kc, ok := newObj.(*v1beta1.Kconfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a Kconfig object but got %T", newObj)
+	}
+	// a kconfig being labeled as protected is protected by the same update
+	if old.Labels[controller.ProtectedKconfigLabel] != "true" && kc.Labels[controller.ProtectedKconfigLabel] != "true" {
+		return nil, nil
+	}
+	if allowed, err := r.fromController(ctx); err != nil || allowed {
+		return nil, err
+	}
+	switch {
+	case !equality.Semantic.DeepEqual(old.Spec, kc.Spec):
+		return nil, fmt.Errorf("kconfig %s is protected, propose changes as a KconfigChangeRequest", kc.Name)
+	case kc.Annotations[controller.RollbackToAnnotation] != old.Annotations[controller.RollbackToAnnotation]:
+		return nil, fmt.Errorf("kconfig %s is protected, propose a rollback as a KconfigChangeRequest", kc.Name)
+	case old.Labels[controller.ProtectedKconfigLabel] == "true" && kc.Labels[controller.ProtectedKconfigLabel] != "true":
+		return nil, fmt.Errorf("kconfig %s is protected, its protection cannot be removed", kc.Name)
+	}
+	return nil, nil
+}
+
+func (r *KconfigProtection) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	kc, ok := obj.(*v1beta1.Kconfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a Kconfig object but got %T", obj)
+	}
+	if kc.Labels[controller.ProtectedKconfigLabel] != "true" {
+		return nil, nil
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get admission request: %s", err.Error())
+	}
+	if req.UserInfo.Username == r.ControllerUsername || namespaceControllerUsernames[req.UserInfo.Username] {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("kconfig %s is protected, propose its deletion as a KconfigChangeRequest", kc.Name)
+}
+
+// fromController reports whether the admission request is made by the controller
+func (r *KconfigProtection) fromController(ctx context.Context) (bool, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not get admission request: %s", err.Error())
+	}
+	return req.UserInfo.Username == r.ControllerUsername, nil
+}
+
+// ControllerUsername looks up the user the controller authenticates as
+func ControllerUsername(ctx context.Context, c client.Client) (string, error) {
+	review := &authenticationv1.SelfSubjectReview{}
+	if err := c.Create(ctx, review); err != nil {
+		return "", fmt.Errorf("error creating selfSubjectReview: %s", err.Error())
+	}
+	return review.Status.UserInfo.Username, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/controller"
+)
+
+func TestKconfigProtection(t *testing.T) {
+	protection := &KconfigProtection{ControllerUsername: "controller"}
+	requestBy := func(username string) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: username}}}
+		return admission.NewContextWithRequest(context.Background(), req)
+	}
+	protected := &v1beta1.Kconfig{ObjectMeta: v12.ObjectMeta{Name: "kc", Labels: map[string]string{controller.ProtectedKconfigLabel: "true"}}}
+	unprotected := &v1beta1.Kconfig{ObjectMeta: v12.ObjectMeta{Name: "kc"}}
+
+	if _, err := protection.ValidateCreate(requestBy("alice"), protected); err != nil {
+		t.Errorf("expected create of a protected kconfig to be allowed but got %s", err.Error())
+	}
+
+	for username, allowed := range map[string]bool{
+		"alice":      false,
+		"controller": true,
+		"system:serviceaccount:kube-system:namespace-controller": true,
+	} {
+		if _, err := protection.ValidateDelete(requestBy(username), protected); (err == nil) != allowed {
+			t.Errorf("expected delete of a protected kconfig by %s allowed to be %t but got %v", username, allowed, err)
+		}
+	}
+	if _, err := protection.ValidateDelete(requestBy("alice"), unprotected); err != nil {
+		t.Errorf("expected delete of an unprotected kconfig to be allowed but got %s", err.Error())
+	}
+
+	value := "debug"
+	edited := func(kc *v1beta1.Kconfig, labels map[string]string) *v1beta1.Kconfig {
+		kc = kc.DeepCopy()
+		kc.Labels = labels
+		kc.Spec.EnvConfigs = []v1beta1.EnvConfig{{Type: "Value", Key: "LOG_LEVEL", Value: &value}}
+		return kc
+	}
+	protectedLabels := map[string]string{controller.ProtectedKconfigLabel: "true"}
+	updates := []struct {
+		name     string
+		old, new *v1beta1.Kconfig
+		allowed  bool
+	}{
+		{"spec edit of a protected kconfig", protected, edited(protected, protectedLabels), false},
+		{"spec edit of an unprotected kconfig", unprotected, edited(unprotected, nil), true},
+		{"spec edit of a kconfig being protected", unprotected, edited(unprotected, protectedLabels), false},
+		{"protection of a kconfig", unprotected, protected, true},
+		{"unprotection of a kconfig", protected, unprotected, false},
+	}
+	for _, u := range updates {
+		if _, err := protection.ValidateUpdate(requestBy("alice"), u.old, u.new); (err == nil) != u.allowed {
+			t.Errorf("expected %s allowed to be %t but got %v", u.name, u.allowed, err)
+		}
+	}
+	if _, err := protection.ValidateUpdate(requestBy("controller"), protected, edited(protected, protectedLabels)); err != nil {
+		t.Errorf("expected spec edit of a protected kconfig by the controller to be allowed but got %s", err.Error())
+	}
+}
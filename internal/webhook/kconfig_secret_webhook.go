@@ -0,0 +1,205 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/envelope"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var kconfigSecretExtractorLog = logf.Log.WithName("kconfig-secret-extractor")
+
+func SetupKconfigSecretExtractorWithManager(mgr ctrl.Manager, key *rsa.PrivateKey) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1beta1.Kconfig{}).
+		WithDefaulter(
+			&KconfigSecretExtractor{
+				Key: key,
+			},
+		).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-kconfigcontroller-atteg-com-v1beta1-kconfig,mutating=true,failurePolicy=fail,sideEffects=None,groups=kconfigcontroller.atteg.com,resources=kconfigs,verbs=create;update,versions=v1beta1,name=secret-extractor.kconfigcontroller.aeg.cloud,admissionReviewVersions=v1
+
+// KconfigSecretExtractor encrypts the plaintext values of Secret type EnvConfigs at admission time, so
+// they are never persisted in the Kconfig itself. The controller decrypts the resulting encryptedValue
+// into the generated Secret once the Kconfig is admitted. Without a key, Kconfigs introducing plaintext
+// secret values are rejected, values stored before the webhook was set up are left to the controller.
+type KconfigSecretExtractor struct {
+	// Key is the controller's decryption key, whose public key values are encrypted against
+	Key *rsa.PrivateKey
+}
+
+const (
+	LastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+var _ webhook.CustomDefaulter = &KconfigSecretExtractor{}
+
+func (r *KconfigSecretExtractor) Default(ctx context.Context, obj runtime.Object) error {
+	kc, ok := obj.(*v1beta1.Kconfig)
+	if !ok {
+		return fmt.Errorf("expected a Kconfig object but got %T", obj)
+	}
+	stored := storedKconfig(ctx)
+	if r.Key == nil {
+		return rejectSecretValues(kc, stored)
+	}
+	seal := r.sealer(stored)
+	extracted, err := extractSecretValues(kc, seal)
+	if err != nil {
+		return fmt.Errorf("could not encrypt secret values: %s", err.Error())
+	}
+	if !extracted {
+		return nil
+	}
+	if err := scrubLastAppliedConfig(kc, seal); err != nil {
+		return fmt.Errorf("could not scrub %s: %s", LastAppliedConfigAnnotation, err.Error())
+	}
+	return nil
+}
+
+// storedKconfig returns the Kconfig being updated, or an empty Kconfig on create
+func storedKconfig(ctx context.Context) *v1beta1.Kconfig {
+	stored := &v1beta1.Kconfig{}
+	if req, err := admission.RequestFromContext(ctx); err == nil && len(req.OldObject.Raw) > 0 {
+		if err := json.Unmarshal(req.OldObject.Raw, stored); err != nil {
+			kconfigSecretExtractorLog.Error(err, "could not read stored kconfig")
+		}
+	}
+	return stored
+}
+
+// rejectSecretValues rejects plaintext values of Secret type EnvConfigs that are not stored already.
+// Stored values predate the webhook and are moved into the generated Secret by the controller.
+func rejectSecretValues(kc, stored *v1beta1.Kconfig) error {
+	existing := make(map[string]bool)
+	for _, ec := range stored.Spec.EnvConfigs {
+		if strings.ToLower(ec.Type) == "secret" && ec.Value != nil {
+			existing[ec.Key+"="+*ec.Value] = true
+		}
+	}
+	for _, ec := range kc.Spec.EnvConfigs {
+		if strings.ToLower(ec.Type) == "secret" && ec.Value != nil && !existing[ec.Key+"="+*ec.Value] {
+			return fmt.Errorf("secret value of %s must be given as encryptedValue or secretKeyRef, no decryption key is configured", ec.Key)
+		}
+	}
+	return nil
+}
+
+// sealer encrypts the value of a key. Values unchanged from the stored Kconfig keep their encrypted
+// value, so that reapplying a manifest does not change the Kconfig.
+func (r *KconfigSecretExtractor) sealer(stored *v1beta1.Kconfig) func(key, value string) (string, error) {
+	sealed := make(map[string]string)
+	for _, ec := range stored.Spec.EnvConfigs {
+		if ec.EncryptedValue == nil {
+			continue
+		}
+		if plaintext, err := envelope.Decrypt(r.Key, *ec.EncryptedValue); err == nil {
+			sealed[ec.Key+"="+string(plaintext)] = *ec.EncryptedValue
+		}
+	}
+	return func(key, value string) (string, error) {
+		if encrypted, ok := sealed[key+"="+value]; ok {
+			return encrypted, nil
+		}
+		encrypted, err := envelope.Encrypt(&r.Key.PublicKey, []byte(value))
+		if err != nil {
+			return "", err
+		}
+		sealed[key+"="+value] = encrypted
+		return encrypted, nil
+	}
+}
+
+// extractSecretValues replaces the values of Secret type EnvConfigs with their encrypted value and
+// reports whether there were any
+func extractSecretValues(kc *v1beta1.Kconfig, seal func(key, value string) (string, error)) (bool, error) {
+	extracted := false
+	for i, ec := range kc.Spec.EnvConfigs {
+		if strings.ToLower(ec.Type) != "secret" || ec.Value == nil {
+			continue
+		}
+		encrypted, err := seal(ec.Key, *ec.Value)
+		if err != nil {
+			return false, err
+		}
+		kc.Spec.EnvConfigs[i].Value = nil
+		kc.Spec.EnvConfigs[i].EncryptedValue = &encrypted
+		extracted = true
+	}
+	return extracted, nil
+}
+
+// scrubLastAppliedConfig applies the same extraction to the manifest kept by client-side apply. The
+// manifest is edited as JSON, so that it keeps exactly the fields that were applied.
+func scrubLastAppliedConfig(kc *v1beta1.Kconfig, seal func(key, value string) (string, error)) error {
+	lastApplied, ok := kc.Annotations[LastAppliedConfigAnnotation]
+	if !ok {
+		return nil
+	}
+	var applied map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(lastApplied))
+	decoder.UseNumber()
+	if err := decoder.Decode(&applied); err != nil {
+		return err
+	}
+	spec, _ := applied["spec"].(map[string]interface{})
+	envConfigs, _ := spec["envConfigs"].([]interface{})
+	scrubbed := false
+	for _, item := range envConfigs {
+		ec, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ecType, _ := ec["type"].(string)
+		value, ok := ec["value"].(string)
+		if strings.ToLower(ecType) != "secret" || !ok {
+			continue
+		}
+		key, _ := ec["key"].(string)
+		encrypted, err := seal(key, value)
+		if err != nil {
+			return err
+		}
+		delete(ec, "value")
+		ec["encryptedValue"] = encrypted
+		scrubbed = true
+	}
+	if !scrubbed {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(applied); err != nil {
+		return err
+	}
+	kc.Annotations[LastAppliedConfigAnnotation] = buf.String()
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/envelope"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExtractSecretValues(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractor := &KconfigSecretExtractor{Key: key}
+	password, plain := "hunter2", "visible"
+	kc := &v1beta1.Kconfig{
+		Spec: v1beta1.KconfigSpec{
+			EnvConfigs: []v1beta1.EnvConfig{
+				{Type: "Secret", Key: "PASSWORD", Value: &password},
+				{Type: "Value", Key: "PLAIN", Value: &plain},
+			},
+		},
+	}
+	extracted, err := extractSecretValues(kc, extractor.sealer(&v1beta1.Kconfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extracted {
+		t.Fatal("expected secret values to be extracted")
+	}
+	secret := kc.Spec.EnvConfigs[0]
+	if secret.Value != nil || secret.EncryptedValue == nil {
+		t.Fatalf("expected the secret value to be replaced by an encrypted value but got %+v", secret)
+	}
+	decrypted, err := envelope.Decrypt(key, *secret.EncryptedValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != password {
+		t.Errorf("expected encrypted value of %s but got %s", password, decrypted)
+	}
+	if kc.Spec.EnvConfigs[1].Value == nil || kc.Spec.EnvConfigs[1].EncryptedValue != nil {
+		t.Errorf("expected value envConfig to be left alone but got %+v", kc.Spec.EnvConfigs[1])
+	}
+
+	extracted, err = extractSecretValues(kc, extractor.sealer(&v1beta1.Kconfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extracted {
+		t.Error("expected nothing to extract from an extracted kconfig")
+	}
+}
+
+func TestScrubLastAppliedConfig(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractor := &KconfigSecretExtractor{Key: key}
+	lastApplied := `{"apiVersion":"kconfigcontroller.atteg.com/v1beta1","kind":"Kconfig",` +
+		`"metadata":{"name":"app","namespace":"default"},"spec":{"unknownField":12345678901234567890,` +
+		`"envConfigs":[{"type":"Secret","key":"PASSWORD","value":"hunter2"},{"type":"Value","key":"PLAIN","value":"visible"}]}}`
+	kc := &v1beta1.Kconfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{LastAppliedConfigAnnotation: lastApplied},
+		},
+	}
+	if err := scrubLastAppliedConfig(kc, extractor.sealer(&v1beta1.Kconfig{})); err != nil {
+		t.Fatal(err)
+	}
+	scrubbed := kc.Annotations[LastAppliedConfigAnnotation]
+	if strings.Contains(scrubbed, "hunter2") {
+		t.Fatalf("expected the secret value to be removed but got %s", scrubbed)
+	}
+	var applied struct {
+		Metadata map[string]interface{} `json:"metadata"`
+		Spec     map[string]json.RawMessage
+		Status   *json.RawMessage `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(scrubbed), &applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied.Status != nil || len(applied.Metadata) != 2 || len(applied.Spec) != 2 {
+		t.Errorf("expected only the applied fields to be kept but got %s", scrubbed)
+	}
+	if string(applied.Spec["unknownField"]) != "12345678901234567890" {
+		t.Errorf("expected unknown fields to be kept unchanged but got %s", applied.Spec["unknownField"])
+	}
+	var envConfigs []v1beta1.EnvConfig
+	if err := json.Unmarshal(applied.Spec["envConfigs"], &envConfigs); err != nil {
+		t.Fatal(err)
+	}
+	if len(envConfigs) != 2 || envConfigs[0].Value != nil || envConfigs[0].EncryptedValue == nil {
+		t.Fatalf("expected the secret value to be encrypted but got %s", applied.Spec["envConfigs"])
+	}
+	decrypted, err := envelope.Decrypt(key, *envConfigs[0].EncryptedValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != "hunter2" {
+		t.Errorf("expected encrypted value of hunter2 but got %s", decrypted)
+	}
+	if envConfigs[1].Value == nil || *envConfigs[1].Value != "visible" {
+		t.Errorf("expected value envConfig to be left alone but got %+v", envConfigs[1])
+	}
+}
+
+func TestRejectSecretValues(t *testing.T) {
+	legacy, changed := "legacy", "changed"
+	stored := &v1beta1.Kconfig{Spec: v1beta1.KconfigSpec{EnvConfigs: []v1beta1.EnvConfig{
+		{Type: "Secret", Key: "PASSWORD", Value: &legacy},
+	}}}
+	if err := rejectSecretValues(stored.DeepCopy(), stored); err != nil {
+		t.Errorf("expected a stored secret value to be admitted but got %s", err.Error())
+	}
+	kc := stored.DeepCopy()
+	kc.Spec.EnvConfigs[0].Value = &changed
+	if err := rejectSecretValues(kc, stored); err == nil {
+		t.Error("expected a changed secret value to be rejected")
+	}
+	if err := rejectSecretValues(stored, &v1beta1.Kconfig{}); err == nil {
+		t.Error("expected a new secret value to be rejected")
+	}
+	kc.Spec.EnvConfigs[0].Type = "Value"
+	if err := rejectSecretValues(kc, &v1beta1.Kconfig{}); err != nil {
+		t.Errorf("expected a plain value to be admitted but got %s", err.Error())
+	}
+}
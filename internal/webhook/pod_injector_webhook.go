@@ -17,15 +17,21 @@ package webhook
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/controller"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -34,27 +40,38 @@ import (
 
 var podConfigInjectorLog = logf.Log.WithName("pod-config-injector")
 
-func SetupPodConfigInjectorWithManager(mgr ctrl.Manager, sel *v12.LabelSelector) error {
+func SetupPodConfigInjectorWithManager(mgr ctrl.Manager, sel *v12.LabelSelector, envPrecedence string) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&v1.Pod{}).
 		WithDefaulter(
 			&PodConfigInjector{
 				Client:                   mgr.GetClient(),
+				PodReader:                mgr.GetAPIReader(),
 				DefaultContainerSelector: sel,
+				EnvPrecedence:            envPrecedence,
 			},
 		).
 		Complete()
 }
 
-// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=config-injector.kconfigcontroller.aeg.cloud,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=config-injector.kconfigcontroller.aeg.cloud,admissionReviewVersions=v1,reinvocationPolicy=IfNeeded
 
 type PodConfigInjector struct {
-	Client                   client.Client
+	Client client.Client
+	// PodReader lists the pods of a workload to place a new pod of it in a canary variant, straight from
+	// the API server so that recently admitted pods are counted
+	PodReader                client.Reader
 	DefaultContainerSelector *v12.LabelSelector
+	// EnvPrecedence is the default precedence between declared and injected envs of the same name
+	EnvPrecedence string
 }
 
 const (
-	InjectConfigAnnotation       = "kconfigcontroller.atteg.com/inject"
+	InjectConfigAnnotation       = controller.InjectConfigAnnotation
 	ExclusiveEnvConfigAnnotation = "kconfigcontroller.atteg.com/exclusive-env"
+	// InjectedEnvsAnnotation records the names of the injected envs by container, as JSON object of name lists
+	InjectedEnvsAnnotation = "kconfigcontroller.atteg.com/injected-envs"
+	// EnvPrecedenceAnnotation overrides the env precedence for a pod
+	EnvPrecedenceAnnotation = "kconfigcontroller.atteg.com/env-precedence"
 )
 
 var _ webhook.CustomDefaulter = &PodConfigInjector{}
@@ -76,13 +93,43 @@ func (r *PodConfigInjector) Default(ctx context.Context, obj runtime.Object) err
 		return fmt.Errorf("could not get kconfigbininglist: %s", err.Error())
 	}
 
-	// cleanup old pod env configs
-	if pod.Annotations == nil || strings.ToLower(pod.Annotations[ExclusiveEnvConfigAnnotation]) == "true" {
-		pod.Spec.Containers[0].Env = []v1.EnvVar{}
+	precedence := r.EnvPrecedence
+	if val, ok := pod.Annotations[EnvPrecedenceAnnotation]; ok {
+		precedence = val
+	}
+	if !ValidEnvPrecedence(precedence) {
+		return fmt.Errorf("invalid env precedence %s", precedence)
+	}
+
+	// a pinned pod is injected with the envs of the revisions it is pinned to
+	pins := controller.PinnedRevisions(pod.Annotations)
+	revisions := v1beta1.KconfigRevisionList{}
+	// unresolved maps the bindings named by pins that do not resolve to these pins
+	unresolved := make(map[string]string)
+	if len(pins) > 0 {
+		if err := r.Client.List(ctx, &revisions, client.InNamespace(pod.Namespace)); err != nil {
+			return fmt.Errorf("could not get kconfigrevisionlist: %s", err.Error())
+		}
+		for _, pin := range pins {
+			if pinResolves(revisions.Items, pin) {
+				continue
+			}
+			if kcName, ok := controller.PinnedKconfigName(pin); ok {
+				unresolved[kcName] = pin
+			} else {
+				podConfigInjectorLog.Info(fmt.Sprintf("ignoring pin %s of %s - no kconfigrevision found", pin, pod.Name))
+			}
+		}
 	}
 
-	selecting := make([]v1beta1.KconfigBindingSpec, 0)
+	selecting := make([]v1beta1.KconfigBinding, 0)
+	provenance := make(map[string]injectedBinding)
+	now := time.Now()
 	for _, kcb := range kcbs.Items {
+		// bindings being deleted are rolling their workloads off the config
+		if !kcb.DeletionTimestamp.IsZero() {
+			continue
+		}
 		ls, err := v12.LabelSelectorAsSelector(&kcb.Spec.Selector)
 		if err != nil {
 			podConfigInjectorLog.Error(err, fmt.Sprintf("couldn't get selector of kcb: %s", err.Error()))
@@ -90,38 +137,226 @@ func (r *PodConfigInjector) Default(ctx context.Context, obj runtime.Object) err
 		}
 
 		if ls.Matches(labels.Set(pod.Labels)) {
-			selecting = append(selecting, kcb.Spec)
+			// a binding must not inject other envs than the revision the pod is pinned to
+			if pin, ok := unresolved[kcb.Name]; ok {
+				podConfigInjectorLog.Info(fmt.Sprintf("skipping kcb %s for %s - pinned kconfigrevision %s not found", kcb.Name, pod.Name, pin))
+				continue
+			}
+			injected := newInjectedBinding(&kcb)
+			if rev := controller.PinnedRevision(revisions.Items, pins, kcb.Name); rev != nil {
+				kcb.Spec.Envs = controller.RevisionEnvs(rev)
+				injected = injectedBinding{Name: kcb.Name, Generation: kcb.Generation, PinnedRevision: rev.Name}
+				provenance[kcb.Name] = injected
+				selecting = append(selecting, kcb)
+				continue
+			}
+			// a rolled back binding injects the envs and canary it was rolled back to, and one outside of its
+			// maintenance windows those last rolled out
+			envs, canary := controller.InjectedEnvs(&kcb, now)
+			kcb.Spec.Envs = envs
+			if canary != nil {
+				variant, err := r.canaryVariant(ctx, pod, kcb.Name, ls, canary)
+				if err != nil {
+					return fmt.Errorf("could not place pod in a canary variant of kconfigbinding %s: %s", kcb.Name, err.Error())
+				}
+				if variant == controller.CanaryVariant {
+					kcb.Spec.Envs = canary.Envs
+				}
+				pod.Annotations[controller.CanaryVariantAnnotation(kcb.Name)] = variant
+				injected.Variant = variant
+			}
+			// record the config of the injected envs, so that stale pods can be found
+			hash, err := controller.ConfigHash(ctx, r.Client, &kcb, kcb.Spec.Envs)
+			if err != nil {
+				return fmt.Errorf("could not compute config hash of kconfigbinding %s: %s", kcb.Name, err.Error())
+			}
+			injected.ConfigHash = hash
+			provenance[kcb.Name] = injected
+			selecting = append(selecting, kcb)
 		}
 	}
-	// sort by level
-	sort.Sort(ByLevel(selecting))
-	// add each to pod
-	for _, kcb := range selecting {
-		for i, container := range pod.Spec.Containers {
-			labelsForContainer := labels.Set{"name": container.Name}
-			labelSelector := kcb.ContainerSelector
+	// higher levels take precedence, equal levels are ordered by name
+	sort.Slice(selecting, func(i, j int) bool {
+		if selecting[i].Spec.Level != selecting[j].Spec.Level {
+			return selecting[i].Spec.Level < selecting[j].Spec.Level
+		}
+		return selecting[i].Name < selecting[j].Name
+	})
+	exclusive := strings.ToLower(pod.Annotations[ExclusiveEnvConfigAnnotation]) == "true"
+	// envs injected by an earlier invocation, e.g. on reinvocation after other webhooks added containers
+	previous := make(map[string][]string)
+	if val, ok := pod.Annotations[InjectedEnvsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(val), &previous); err != nil {
+			podConfigInjectorLog.Error(err, fmt.Sprintf("ignoring malformed %s of %s", InjectedEnvsAnnotation, pod.Name))
+		}
+	}
+	record := make(map[string][]string)
+	applied := make(map[string]bool)
+	sources := make(map[string]map[string]string)
+	for _, c := range podContainers(pod) {
+		container := c.Container
+		owned := make(map[string]bool)
+		for _, name := range previous[container.Name] {
+			owned[name] = true
+		}
+		bound := make([]boundEnvs, 0)
+		for _, kcb := range selecting {
+			labelSelector := kcb.Spec.ContainerSelector
 			if labelSelector == nil {
 				labelSelector = r.DefaultContainerSelector
 			}
-			selector, err := v12.LabelSelectorAsSelector(labelSelector)
+			selected, err := selectsContainer(labelSelector, c)
 			if err != nil {
 				podConfigInjectorLog.Error(err, fmt.Sprintf("error reading kcb containerSelector: %s", err.Error()))
 				continue
 			}
-			if selector.Matches(labelsForContainer) {
-				if pod.Spec.Containers[i].Env == nil {
-					pod.Spec.Containers[i].Env = make([]v1.EnvVar, 0)
+			if selected {
+				bound = append(bound, boundEnvs{Binding: kcb.Name, Envs: kcb.Spec.Envs})
+			}
+		}
+		if len(bound) == 0 && len(owned) == 0 {
+			continue
+		}
+		if len(bound) > 0 {
+			injected := make([]injectedBinding, 0, len(bound))
+			for _, b := range bound {
+				applied[b.Binding] = true
+				injected = append(injected, provenance[b.Binding])
+			}
+			bound = append(bound, boundEnvs{Binding: RevisionEnvSource, Envs: []v1.EnvVar{revisionEnv(injected)}})
+		}
+		// exclusive-env replaces the declared envs of the containers config is injected into
+		declared := container.Env
+		if exclusive {
+			declared = make([]v1.EnvVar, 0)
+			for _, env := range container.Env {
+				if owned[env.Name] {
+					declared = append(declared, env)
 				}
-				pod.Spec.Containers[i].Env = append(pod.Spec.Containers[0].Env, kcb.Envs...)
 			}
 		}
+		merged, names, err := mergeEnvs(declared, owned, bound, precedence)
+		if err != nil {
+			return fmt.Errorf("could not inject config into container %s: %s", container.Name, err.Error())
+		}
+		container.Env = merged
+		if len(names) > 0 {
+			record[container.Name] = names
+			sources[container.Name] = envSources(bound, names)
+		}
+	}
+	if err := setJSONAnnotation(pod, InjectedEnvsAnnotation, record, len(record) == 0); err != nil {
+		return err
+	}
+	injected := make([]injectedBinding, 0, len(applied))
+	for _, kcb := range selecting {
+		// only pods a binding injected envs into are stale once its config changes
+		if !applied[kcb.Name] {
+			delete(pod.Annotations, controller.InjectedConfigAnnotation(kcb.Name))
+			continue
+		}
+		injected = append(injected, provenance[kcb.Name])
+		if hash := provenance[kcb.Name].ConfigHash; hash != "" {
+			pod.Annotations[controller.InjectedConfigAnnotation(kcb.Name)] = hash
+		}
+	}
+	if err := setJSONAnnotation(pod, InjectedBindingsAnnotation, injected, len(injected) == 0); err != nil {
+		return err
+	}
+	recordSources := strings.ToLower(pod.Annotations[RecordEnvSourcesAnnotation]) == "true"
+	return setJSONAnnotation(pod, EnvSourcesAnnotation, sources, !recordSources || len(sources) == 0)
+}
+
+// canaryVariant returns the canary variant of a binding for a pod selected by ls. A pod keeps the
+// variant recorded by an earlier invocation. A pod with a name, or in Owner mode a pod of a workload,
+// is bucketed by that name, so that it keeps its variant when recreated. A pod of a workload created
+// with a generated name, which it doesn't have yet, gets the variant the workload is short of.
+func (r *PodConfigInjector) canaryVariant(ctx context.Context, pod *v1.Pod, kcbName string, ls labels.Selector, canary *v1beta1.Canary) (string, error) {
+	switch variant := pod.Annotations[controller.CanaryVariantAnnotation(kcbName)]; variant {
+	case controller.CanaryVariant, controller.StableVariant:
+		return variant, nil
+	}
+	workload := podWorkload(pod)
+	key := pod.Name
+	if canary.BucketBy == controller.OwnerCanaryBucketBy && workload != "" {
+		key = workload
+	}
+	if key != "" || workload == "" {
+		return bucketVariant(ctx, pod, kcbName, key, canary.Percent), nil
+	}
+	var pods v1.PodList
+	if err := r.PodReader.List(ctx, &pods, client.InNamespace(pod.Namespace), client.MatchingLabelsSelector{Selector: ls}); err != nil {
+		return "", fmt.Errorf("error getting podList: %s", err.Error())
+	}
+	return shareVariant(ctx, pods.Items, pod, workload, kcbName, canary.Percent), nil
+}
+
+// shareVariant returns the variant the pods of a workload injected with the same config of a binding
+// are short of, so that the canary share holds as pods of the workload come and go. A workload on its
+// share, e.g. while pods admitted at the same time are not counted yet, is bucketed by admission
+// request, i.e. at random.
+func shareVariant(ctx context.Context, pods []v1.Pod, pod *v1.Pod, workload, kcbName string, percent int32) string {
+	config := pod.Annotations[controller.ConfigHashAnnotation(kcbName)]
+	var total, canaries int
+	for i := range pods {
+		other := &pods[i]
+		variant := other.Annotations[controller.CanaryVariantAnnotation(kcbName)]
+		if variant == "" || !other.DeletionTimestamp.IsZero() || podWorkload(other) != workload ||
+			other.Annotations[controller.ConfigHashAnnotation(kcbName)] != config {
+			continue
+		}
+		total++
+		if variant == controller.CanaryVariant {
+			canaries++
+		}
+	}
+	switch share := int(percent) * total; {
+	case canaries*100 < share:
+		return controller.CanaryVariant
+	case canaries*100 > share:
+		return controller.StableVariant
+	}
+	return bucketVariant(ctx, pod, kcbName, "", percent)
+}
+
+// bucketVariant deterministically places a pod in one of 100 buckets of a binding by key, and in the
+// canary variant if the bucket is below the percentage. An empty key places the pod by its admission
+// request, i.e. at random.
+func bucketVariant(ctx context.Context, pod *v1.Pod, kcbName, key string, percent int32) string {
+	if key == "" {
+		key = pod.GenerateName
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			key += string(req.UID)
+		}
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(kcbName + "/" + key))
+	if int(hash.Sum32()%100) < int(percent) {
+		return controller.CanaryVariant
 	}
-	return nil
+	return controller.StableVariant
 }
 
-// ByLevel sort function for sorting array of KconfigBindingSpecs by their level
-type ByLevel []v1beta1.KconfigBindingSpec
+// podWorkload returns the kind and name of the workload controlling a pod, or an empty string. The
+// ReplicaSet of a Deployment, which changes with each rollout, resolves to the Deployment by the
+// pod-template-hash suffix of its name.
+func podWorkload(pod *v1.Pod) string {
+	owner := v12.GetControllerOf(pod)
+	if owner == nil {
+		return ""
+	}
+	if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+		return "Deployment/" + strings.TrimSuffix(owner.Name, "-"+hash)
+	}
+	return owner.Kind + "/" + owner.Name
+}
 
-func (c ByLevel) Len() int           { return len(c) }
-func (c ByLevel) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
-func (c ByLevel) Less(i, j int) bool { return c[i].Level < c[j].Level }
+// pinResolves reports whether a pin names one of the revisions by name or hash
+func pinResolves(revisions []v1beta1.KconfigRevision, pin string) bool {
+	for _, rev := range revisions {
+		if pin == rev.Name || pin == rev.Spec.Hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	"github.com/att-cloudnative-labs/kconfig-controller/internal/controller"
+)
+
+func TestCanaryVariant(t *testing.T) {
+	r := &PodConfigInjector{}
+	canary := &v1beta1.Canary{Percent: 50, BucketBy: "Pod"}
+	admitted := func(uid int) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{UID: types.UID(fmt.Sprintf("uid-%d", uid))}}
+		return admission.NewContextWithRequest(context.Background(), req)
+	}
+	variant := func(ctx context.Context, pod *v1.Pod, canary *v1beta1.Canary) string {
+		variant, err := r.canaryVariant(ctx, pod, "kcb", labels.Everything(), canary)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		return variant
+	}
+	variants := make(map[string]bool)
+	for uid := 0; uid < 20; uid++ {
+		pod := &v1.Pod{ObjectMeta: v12.ObjectMeta{GenerateName: "app-", Annotations: map[string]string{}}}
+		first := variant(admitted(uid), pod, canary)
+		variants[first] = true
+		// a reinvocation is another admission request of the same pod
+		pod.Annotations[controller.CanaryVariantAnnotation("kcb")] = first
+		for reinvocation := 100; reinvocation < 110; reinvocation++ {
+			if again := variant(admitted(reinvocation), pod, canary); again != first {
+				t.Fatalf("expected variant %s on reinvocation but got %s", first, again)
+			}
+		}
+	}
+	if !variants[controller.CanaryVariant] || !variants[controller.StableVariant] {
+		t.Errorf("expected both variants for bare pods with generated names but got %v", variants)
+	}
+
+	named := &v1.Pod{ObjectMeta: v12.ObjectMeta{Name: "app-0"}}
+	if variant(admitted(0), named, canary) != variant(admitted(1), named, canary) {
+		t.Errorf("expected the same variant for a named pod")
+	}
+	if got := variant(admitted(0), named, &v1beta1.Canary{Percent: 100}); got != controller.CanaryVariant {
+		t.Errorf("expected %s at 100 percent but got %s", controller.CanaryVariant, got)
+	}
+
+	// the pods of all ReplicaSets of a deployment share the variant of the deployment
+	owned := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		deployment := fmt.Sprintf("app%d", i)
+		first := variant(admitted(0), deploymentPod(deployment, "5d8f7b9c4"), &v1beta1.Canary{Percent: 50, BucketBy: "Owner"})
+		if again := variant(admitted(1), deploymentPod(deployment, "6c7d9f8b5"), &v1beta1.Canary{Percent: 50, BucketBy: "Owner"}); again != first {
+			t.Fatalf("expected variant %s for a new ReplicaSet of %s but got %s", first, deployment, again)
+		}
+		owned[first] = true
+	}
+	if len(owned) != 2 {
+		t.Errorf("expected both variants across deployments but got %v", owned)
+	}
+}
+
+func TestShareVariant(t *testing.T) {
+	annotated := func(pod *v1.Pod, config, variant string) v1.Pod {
+		pod.Annotations = map[string]string{
+			controller.ConfigHashAnnotation("kcb"):    config,
+			controller.CanaryVariantAnnotation("kcb"): variant,
+		}
+		return *pod
+	}
+	pod := deploymentPod("app", "6c7d9f8b5")
+	pod.Annotations = map[string]string{controller.ConfigHashAnnotation("kcb"): "config"}
+	stable := annotated(deploymentPod("app", "6c7d9f8b5"), "config", controller.StableVariant)
+	canary := annotated(deploymentPod("app", "5d8f7b9c4"), "config", controller.CanaryVariant)
+	pods := []v1.Pod{
+		stable, stable, stable, canary,
+		// pods of another workload or config don't count
+		annotated(deploymentPod("other", "5d8f7b9c4"), "config", controller.CanaryVariant),
+		annotated(deploymentPod("app", "7f9c8d6b4"), "previous", controller.CanaryVariant),
+	}
+	if got := shareVariant(context.Background(), pods, pod, "Deployment/app", "kcb", 50); got != controller.CanaryVariant {
+		t.Errorf("expected %s for a workload short of canaries but got %s", controller.CanaryVariant, got)
+	}
+	if got := shareVariant(context.Background(), pods, pod, "Deployment/app", "kcb", 10); got != controller.StableVariant {
+		t.Errorf("expected %s for a workload over its canary share but got %s", controller.StableVariant, got)
+	}
+	// a workload on its share is placed by admission request
+	pods = append(pods, canary, canary)
+	placed := make(map[string]bool)
+	for uid := 0; uid < 20; uid++ {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{UID: types.UID(fmt.Sprintf("uid-%d", uid))}}
+		placed[shareVariant(admission.NewContextWithRequest(context.Background(), req), pods, pod, "Deployment/app", "kcb", 50)] = true
+	}
+	if len(placed) != 2 {
+		t.Errorf("expected both variants for a workload on its share but got %v", placed)
+	}
+}
+
+func deploymentPod(deployment, templateHash string) *v1.Pod {
+	controls := true
+	return &v1.Pod{ObjectMeta: v12.ObjectMeta{
+		GenerateName: deployment + "-" + templateHash + "-",
+		Labels:       map[string]string{"pod-template-hash": templateHash},
+		OwnerReferences: []v12.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "ReplicaSet",
+			Name:       deployment + "-" + templateHash,
+			Controller: &controls,
+		}},
+	}}
+}
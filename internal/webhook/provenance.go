@@ -0,0 +1,108 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/att-cloudnative-labs/kconfig-controller/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// InjectedBindingsAnnotation lists the bindings injected into a pod, as JSON array of injectedBindings
+	InjectedBindingsAnnotation = "kconfigcontroller.atteg.com/injected-bindings"
+	// RecordEnvSourcesAnnotation opts a pod into recording the binding each injected env came from
+	RecordEnvSourcesAnnotation = "kconfigcontroller.atteg.com/record-env-sources"
+	// EnvSourcesAnnotation maps the injected envs to their binding by container, as JSON object
+	EnvSourcesAnnotation = "kconfigcontroller.atteg.com/env-sources"
+	// RevisionEnv lists the injected bindings as binding@revision, comma separated in order of precedence
+	RevisionEnv = "KCONFIG_REVISION"
+	// RevisionEnvSource is the source recorded for RevisionEnv
+	RevisionEnvSource = "kconfig-controller"
+)
+
+// injectedBinding records the config of a binding injected into a pod
+type injectedBinding struct {
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	// ConfigHash is the config hash of the injected envs, as recorded in the injected config annotation
+	ConfigHash string `json:"configHash,omitempty"`
+	// PinnedRevision is the KconfigRevision injected instead of the binding envs
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+	Variant        string `json:"variant,omitempty"`
+	RolledBack     bool   `json:"rolledBack,omitempty"`
+}
+
+func newInjectedBinding(kcb *v1beta1.KconfigBinding) injectedBinding {
+	return injectedBinding{
+		Name:       kcb.Name,
+		Generation: kcb.Generation,
+		RolledBack: kcb.Status.Rollback != nil,
+	}
+}
+
+// revision identifies the injected config of the binding by the pinned revision, or by the config
+// hash of the injected envs followed by the canary variant they were chosen for
+func (b injectedBinding) revision() string {
+	switch {
+	case b.PinnedRevision != "":
+		return b.PinnedRevision
+	case b.Variant != "":
+		return b.ConfigHash + "/" + b.Variant
+	}
+	return b.ConfigHash
+}
+
+// revisionEnv returns the RevisionEnv of the bindings injected into a container
+func revisionEnv(bindings []injectedBinding) v1.EnvVar {
+	revisions := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		revisions = append(revisions, b.Name+"@"+b.revision())
+	}
+	return v1.EnvVar{Name: RevisionEnv, Value: strings.Join(revisions, ",")}
+}
+
+// envSources maps the names of injected envs to the binding they came from, the last one binding them
+func envSources(bound []boundEnvs, names []string) map[string]string {
+	sources := make(map[string]string, len(names))
+	for _, b := range bound {
+		for _, env := range b.Envs {
+			sources[env.Name] = b.Binding
+		}
+	}
+	injected := make(map[string]string, len(names))
+	for _, name := range names {
+		injected[name] = sources[name]
+	}
+	return injected
+}
+
+// setJSONAnnotation sets a pod annotation to the JSON encoding of the value, or removes it if empty
+func setJSONAnnotation(pod *v1.Pod, key string, value interface{}, empty bool) error {
+	delete(pod.Annotations, key)
+	if empty {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %s", key, err.Error())
+	}
+	pod.Annotations[key] = string(encoded)
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestProvenance(t *testing.T) {
+	env := revisionEnv([]injectedBinding{
+		{Name: "base", Generation: 3, ConfigHash: "0123456789abcdef"},
+		{Name: "frozen", Generation: 1, ConfigHash: "fedcba9876543210", PinnedRevision: "frozen-rev-2"},
+		{Name: "canaried", Generation: 2, ConfigHash: "0011223344556677", Variant: "canary"},
+	})
+	expected := "base@0123456789abcdef,frozen@frozen-rev-2,canaried@0011223344556677/canary"
+	if env.Name != RevisionEnv || env.Value != expected {
+		t.Errorf("expected %s=%s but got %s=%s", RevisionEnv, expected, env.Name, env.Value)
+	}
+
+	bound := []boundEnvs{
+		{Binding: "base", Envs: []v1.EnvVar{{Name: "A"}, {Name: "B"}}},
+		{Binding: "team", Envs: []v1.EnvVar{{Name: "B"}}},
+		{Binding: RevisionEnvSource, Envs: []v1.EnvVar{env}},
+	}
+	sources := envSources(bound, []string{"A", "B", RevisionEnv})
+	expectedSources := map[string]string{"A": "base", "B": "team", RevisionEnv: RevisionEnvSource}
+	if !reflect.DeepEqual(sources, expectedSources) {
+		t.Errorf("expected %v but got %v", expectedSources, sources)
+	}
+}